@@ -0,0 +1,225 @@
+package sping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecordFilter selects the records of a session to analyze.
+type RecordFilter struct {
+	From    time.Time // only records sent at or after this time if not zero
+	To      time.Time // only records sent before this time if not zero
+	Results []string  // only records with these result classes if not empty
+	Target  string    // only records of this target if not empty
+}
+
+// Match returns true if the record passes the filter.
+func (f *RecordFilter) Match(r *Record) bool {
+	if !f.From.IsZero() && r.Sent.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && !r.Sent.Before(f.To) {
+		return false
+	}
+
+	if f.Target != "" && r.Target != f.Target {
+		return false
+	}
+
+	if len(f.Results) == 0 {
+		return true
+	}
+
+	for _, result := range f.Results {
+		if r.Result == result {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRecords returns the records that pass the filter, sorted by the time
+// that they were sent.
+func FilterRecords(records []*Record, filter *RecordFilter) []*Record {
+	matched := make([]*Record, 0, len(records))
+	for _, record := range records {
+		if filter == nil || filter.Match(record) {
+			matched = append(matched, record)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Sent.Before(matched[j].Sent)
+	})
+	return matched
+}
+
+// RecordStats recomputes the statistics of a session from its records, in
+// the order that they are given.
+func RecordStats(records []*Record) *Stats {
+	stats := new(Stats)
+	for _, record := range records {
+		stats.Send()
+		if record.Result == ResultLimited {
+			stats.Throttle()
+		}
+
+		if !record.Received() {
+			continue
+		}
+
+		stats.Update(record.RTT, nil)
+		if record.Result == ResultCorrupted {
+			stats.Corrupt()
+		}
+
+		if record.Result == ResultOutOfOrder {
+			stats.Misordered()
+		}
+
+		if record.Offset != 0 {
+			stats.Lock()
+			stats.addOffset(record.Offset)
+			stats.Unlock()
+		}
+	}
+	return stats
+}
+
+// Histogram draws the distribution of the round trip times of the received
+// pongs as bins of equal width, one line per bin with bars up to width long.
+func Histogram(records []*Record, bins, width int) []string {
+	var rtts []time.Duration
+	for _, record := range records {
+		if record.Received() {
+			rtts = append(rtts, record.RTT)
+		}
+	}
+
+	if len(rtts) == 0 || bins < 1 {
+		return nil
+	}
+
+	min, max := rtts[0], rtts[0]
+	for _, rtt := range rtts {
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+	}
+
+	// The last bin includes the maximum round trip time
+	size := (max - min) / time.Duration(bins)
+	if size <= 0 {
+		bins, size = 1, 1
+	}
+
+	counts := make([]int, bins)
+	for _, rtt := range rtts {
+		bin := int((rtt - min) / size)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+	}
+
+	most := 0
+	for _, count := range counts {
+		if count > most {
+			most = count
+		}
+	}
+
+	lines := make([]string, 0, bins)
+	for i, count := range counts {
+		low := min + time.Duration(i)*size
+		lines = append(lines, fmt.Sprintf(
+			"%12s | %-*s %d", round(low), width, bar(count, most, width), count,
+		))
+	}
+	return lines
+}
+
+// Timeline draws the mean round trip time and the loss of the session over
+// time, dividing the session into windows of equal duration. Each line is the
+// offset of the window from the start of the session, a bar of the mean round
+// trip time up to width long and the percentage of pings lost.
+func Timeline(records []*Record, windows, width int) []string {
+	if len(records) == 0 || windows < 1 {
+		return nil
+	}
+
+	start, end := records[0].Sent, records[len(records)-1].Sent
+	size := end.Sub(start) / time.Duration(windows)
+	if size <= 0 {
+		windows, size = 1, 1
+	}
+
+	sent := make([]int, windows)
+	received := make([]int, windows)
+	total := make([]time.Duration, windows)
+	for _, record := range records {
+		window := int(record.Sent.Sub(start) / size)
+		if window >= windows {
+			window = windows - 1
+		}
+
+		sent[window]++
+		if record.Received() {
+			received[window]++
+			total[window] += record.RTT
+		}
+	}
+
+	means := make([]time.Duration, windows)
+	var slowest time.Duration
+	for i := range means {
+		if received[i] > 0 {
+			means[i] = total[i] / time.Duration(received[i])
+		}
+		if means[i] > slowest {
+			slowest = means[i]
+		}
+	}
+
+	lines := make([]string, 0, windows)
+	for i := range means {
+		offset := "+" + round(time.Duration(i)*size)
+		if sent[i] == 0 {
+			lines = append(lines, fmt.Sprintf("%12s | %-*s no pings", offset, width, ""))
+			continue
+		}
+
+		loss := 100 * float64(sent[i]-received[i]) / float64(sent[i])
+		lines = append(lines, fmt.Sprintf(
+			"%12s | %-*s %s avg, %0.1f%% loss", offset, width,
+			bar(int(means[i]), int(slowest), width), round(means[i]), loss,
+		))
+	}
+	return lines
+}
+
+// Returns a bar of the value relative to the maximum value up to width long.
+func bar(value, max, width int) string {
+	if max <= 0 || width <= 0 {
+		return ""
+	}
+	return strings.Repeat("#", value*width/max)
+}
+
+// Rounds the duration for display in histograms and timelines.
+func round(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,238 @@
+package sping
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Certificate monitoring defaults
+const (
+	DefaultExpiryWarning = 30 * 24 * time.Hour
+	CertCheckInterval    = time.Hour
+)
+
+// The number of days before each monitored certificate expires, published
+// by name so that it can be collected from the /debug/vars endpoint.
+var certDaysRemaining = expvar.NewMap("cert_days_remaining")
+
+// LoadCertificates parses all of the PEM encoded certificates in a file.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", path, err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate in %s: %s", path, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return certs, nil
+}
+
+// DaysRemaining returns the number of days until the certificate expires,
+// which is negative if the certificate has already expired.
+func DaysRemaining(cert *x509.Certificate) float64 {
+	return time.Until(cert.NotAfter).Hours() / 24
+}
+
+// CertMonitor inspects certificate files at startup and periodically after,
+// logging warnings when a certificate is close to expiring and publishing the
+// days remaining for each certificate as a metric.
+type CertMonitor struct {
+	Paths    []string      // the certificate files to inspect
+	Warning  time.Duration // warn when a certificate expires within this window
+	Interval time.Duration // how often to inspect the certificates
+	stop     chan struct{} // closed to stop the periodic checks
+	once     sync.Once     // ensures the monitor is only stopped once
+}
+
+// NewCertMonitor creates a monitor for the specified certificate files with
+// the default warning window and check interval.
+func NewCertMonitor(paths ...string) *CertMonitor {
+	return &CertMonitor{
+		Paths:    paths,
+		Warning:  DefaultExpiryWarning,
+		Interval: CertCheckInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Check inspects every certificate once, logging a warning for any that are
+// expired or will expire within the warning window.
+func (m *CertMonitor) Check() error {
+	for _, path := range m.Paths {
+		certs, err := LoadCertificates(path)
+		if err != nil {
+			return err
+		}
+
+		for i, cert := range certs {
+			name := path
+			if i > 0 {
+				name = fmt.Sprintf("%s[%d]", path, i)
+			}
+
+			days := DaysRemaining(cert)
+			metric := new(expvar.Float)
+			metric.Set(days)
+			certDaysRemaining.Set(name, metric)
+
+			switch {
+			case days < 0:
+				Output("warning: certificate %s (%s) expired %.0f days ago", name, cert.Subject, -days)
+			case time.Until(cert.NotAfter) < m.Warning:
+				Output("warning: certificate %s (%s) expires in %.0f days", name, cert.Subject, days)
+			}
+		}
+	}
+	return nil
+}
+
+// Start inspects the certificates immediately and then every interval in a
+// background routine until the monitor is stopped.
+func (m *CertMonitor) Start() error {
+	if err := m.Check(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.Check(); err != nil {
+					Output("could not check certificates: %s", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop the periodic certificate checks.
+func (m *CertMonitor) Stop() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+// Creates and starts a monitor for the certificate files, using the specified
+// warning window if it is greater than zero.
+func monitorCerts(warning time.Duration, paths ...string) (*CertMonitor, error) {
+	monitor := NewCertMonitor(paths...)
+	if warning > 0 {
+		monitor.Warning = warning
+	}
+
+	if err := monitor.Start(); err != nil {
+		return nil, fmt.Errorf("could not monitor certificates: %s", err)
+	}
+	return monitor, nil
+}
+
+// CertCheck is the result of a single validation of a certificate config.
+type CertCheck struct {
+	Name string // a description of what was validated
+	Err  error  // nil if the validation passed
+}
+
+// CheckCertificates validates a certificate and key pair against the CA:
+// that the key matches the certificate, that the certificate chains to the
+// CA for the specified usage, that the hostname (if any) matches the SANs of
+// the certificate and that none of the certificates expire within warning.
+func CheckCertificates(certFile, keyFile, caFile, hostname string, usage x509.ExtKeyUsage, warning time.Duration) []CertCheck {
+	checks := make([]CertCheck, 0, 5)
+	check := func(name string, err error) {
+		checks = append(checks, CertCheck{Name: name, Err: err})
+	}
+
+	// Ensure the private key matches the public key of the certificate
+	_, err := tls.LoadX509KeyPair(certFile, keyFile)
+	check(fmt.Sprintf("key %s matches certificate %s", keyFile, certFile), err)
+
+	certs, err := LoadCertificates(certFile)
+	if err != nil {
+		check("load certificate", err)
+		return checks
+	}
+
+	roots, err := LoadCertificates(caFile)
+	if err != nil {
+		check("load certificate authority", err)
+		return checks
+	}
+
+	// Verify the chain from the leaf to the certificate authority
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, root := range roots {
+		opts.Roots.AddCert(root)
+	}
+	for _, intermediate := range certs[1:] {
+		opts.Intermediates.AddCert(intermediate)
+	}
+
+	_, err = certs[0].Verify(opts)
+	check(fmt.Sprintf("certificate %s chains to %s", certFile, caFile), err)
+
+	// Verify the subject alternative names include the hostname
+	if hostname != "" {
+		check(fmt.Sprintf("certificate %s is valid for %s", certFile, hostname), certs[0].VerifyHostname(hostname))
+	}
+
+	// Verify that no certificate in the chain is close to expiring
+	for _, cert := range append(certs, roots...) {
+		var err error
+		if time.Until(cert.NotAfter) < warning {
+			err = fmt.Errorf("expires in %.0f days on %s", DaysRemaining(cert), cert.NotAfter.Format(time.RFC3339))
+		}
+		check(fmt.Sprintf("certificate %q does not expire within %.0f days", cert.Subject, warning.Hours()/24), err)
+	}
+
+	return checks
+}
+
+// CertChecksFailed returns an error if any of the certificate checks failed.
+func CertChecksFailed(checks []CertCheck) error {
+	failed := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d certificate checks failed", failed, len(checks))
+	}
+	return nil
+}
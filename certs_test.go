@@ -0,0 +1,178 @@
+package sping
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"expvar"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes the certificate and its key as PEM files in the directory.
+func writeCertificate(t *testing.T, dir, name string, cert tls.Certificate) (string, string) {
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	if err = ioutil.WriteFile(certFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+	if err = ioutil.WriteFile(keyFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, _ := testCertificate(t, "localhost", time.Now().Add(48*time.Hour))
+	certFile, keyFile := writeCertificate(t, dir, "server", cert)
+
+	certs, err := LoadCertificates(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 1 || certs[0].Subject.CommonName != "localhost" {
+		t.Errorf("unexpected certificates %v", certs)
+	}
+
+	if days := DaysRemaining(certs[0]); days < 1.9 || days > 2 {
+		t.Errorf("expected the certificate to expire in 2 days got %0.2f", days)
+	}
+
+	// Files without certificates, such as keys, are an error
+	if _, err = LoadCertificates(keyFile); err == nil {
+		t.Error("expected an error loading a file without certificates")
+	}
+
+	if _, err = LoadCertificates(filepath.Join(dir, "missing.crt")); err == nil {
+		t.Error("expected an error loading a missing file")
+	}
+}
+
+func TestCertMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	logmsgs = true
+	defer func() {
+		log.SetOutput(os.Stderr)
+		logmsgs = false
+	}()
+
+	expiring, _ := testCertificate(t, "expiring", time.Now().Add(5*24*time.Hour))
+	valid, _ := testCertificate(t, "valid", time.Now().Add(90*24*time.Hour))
+	expiringFile, _ := writeCertificate(t, dir, "expiring", expiring)
+	validFile, _ := writeCertificate(t, dir, "valid", valid)
+
+	monitor := NewCertMonitor(expiringFile, validFile)
+	if err = monitor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	monitor.Stop()
+	monitor.Stop()
+
+	out := buf.String()
+	if !strings.Contains(out, "certificate "+expiringFile) || !strings.Contains(out, "expires in") {
+		t.Errorf("expected a warning for the expiring certificate got %q", out)
+	}
+
+	if strings.Contains(out, validFile) {
+		t.Errorf("expected no warning for the valid certificate got %q", out)
+	}
+
+	// The days remaining of each certificate are published as metrics
+	for path, expected := range map[string]float64{expiringFile: 5, validFile: 90} {
+		metric, ok := certDaysRemaining.Get(path).(*expvar.Float)
+		if !ok {
+			t.Fatalf("no days remaining metric for %s", path)
+		}
+
+		if days := metric.Value(); days < expected-0.1 || days > expected {
+			t.Errorf("expected %s to expire in %0.0f days got %0.2f", path, expected, days)
+		}
+	}
+
+	// Monitors cannot start without their certificates
+	if _, err = monitorCerts(time.Hour, filepath.Join(dir, "missing.crt")); err == nil {
+		t.Error("expected an error monitoring a missing certificate")
+	}
+}
+
+func TestCheckCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Self-signed certificates are their own certificate authority
+	cert, _ := testCertificate(t, "localhost", time.Now().Add(90*24*time.Hour))
+	other, _ := testCertificate(t, "other", time.Now().Add(90*24*time.Hour))
+	certFile, keyFile := writeCertificate(t, dir, "server", cert)
+	otherFile, otherKey := writeCertificate(t, dir, "other", other)
+
+	checks := CheckCertificates(certFile, keyFile, certFile, "localhost", x509.ExtKeyUsageServerAuth, 30*24*time.Hour)
+	if err = CertChecksFailed(checks); err != nil {
+		for _, check := range checks {
+			t.Logf("%s: %v", check.Name, check.Err)
+		}
+		t.Fatal(err)
+	}
+
+	// The key, chain and hostname checks and the expiry of the certificate
+	// and of the certificate authority
+	if len(checks) != 5 {
+		t.Errorf("expected 5 checks got %d", len(checks))
+	}
+
+	// The wrong key, an untrusted authority and the wrong host each fail a
+	// check, while both certificates expire within the warning window.
+	for _, tc := range []struct {
+		key, ca, hostname string
+		warning           time.Duration
+		failed            string
+	}{
+		{otherKey, certFile, "localhost", 0, "1 of 5"},
+		{keyFile, otherFile, "localhost", 0, "1 of 5"},
+		{keyFile, certFile, "example.com", 0, "1 of 5"},
+		{keyFile, certFile, "localhost", 100 * 24 * time.Hour, "2 of 5"},
+	} {
+		checks = CheckCertificates(certFile, tc.key, tc.ca, tc.hostname, x509.ExtKeyUsageServerAuth, tc.warning)
+		if err = CertChecksFailed(checks); err == nil || !strings.HasPrefix(err.Error(), tc.failed) {
+			t.Errorf("expected %s checks to fail with key %s, ca %s, host %s and warning %s got %v", tc.failed, tc.key, tc.ca, tc.hostname, tc.warning, err)
+		}
+	}
+
+	// Missing certificates stop the checks
+	checks = CheckCertificates(filepath.Join(dir, "missing.crt"), keyFile, certFile, "", x509.ExtKeyUsageServerAuth, 0)
+	if len(checks) != 2 || CertChecksFailed(checks) == nil {
+		t.Errorf("expected the key and load checks to fail got %v", checks)
+	}
+}
@@ -0,0 +1,223 @@
+package sping
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// CheckState is the result of a check, whose value is the exit code of a
+// Nagios or Icinga plugin in that state.
+type CheckState int
+
+// Nagios plugin states in order of severity, except for unknown.
+const (
+	CheckOK CheckState = iota
+	CheckWarning
+	CheckCritical
+	CheckUnknown
+)
+
+// String returns the name of the state used in the plugin output.
+func (s CheckState) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Check pings a server a number of times like a Nagios or Icinga plugin,
+// comparing the stats of the pings and the days until the certificates of
+// both the client and the server expire to warning and critical limits.
+type Check struct {
+	Client       *PingClient // the client that pings the server
+	Cold         bool        // time the mutual TLS handshake of the first ping
+	Warning      *Thresholds // the stats that put the check in warning
+	Critical     *Thresholds // the stats that make the check critical
+	WarningDays  float64     // warn if a certificate expires within this many days
+	CriticalDays float64     // critical if a certificate expires within this many days
+	Certs        []string    // the local certificate files to inspect
+}
+
+// CheckResult is the single line of output of a check: the state, a human
+// readable message and the performance data for graphing.
+type CheckResult struct {
+	State    CheckState
+	Message  string
+	Perfdata []string
+}
+
+// String returns the result as a line of Nagios plugin output.
+func (r *CheckResult) String() string {
+	line := fmt.Sprintf("SPING %s - %s", r.State, r.Message)
+	if len(r.Perfdata) > 0 {
+		line += " | " + strings.Join(r.Perfdata, " ")
+	}
+	return line
+}
+
+// Escalate raises the state of the result to state if it is more severe,
+// adding the reason to the message.
+func (r *CheckResult) Escalate(state CheckState, reason string) {
+	if state > r.State {
+		r.State = state
+	}
+	r.Message = fmt.Sprintf("%s, %s", r.Message, reason)
+}
+
+// Run the check against the target of the client, sending the first ping on
+// a new connection if the handshake is timed and the rest on the connection
+// of the client, until the limit of the client is reached.
+func (c *Check) Run(ctx context.Context) *CheckResult {
+	// Certificates that cannot be inspected are a problem with the check
+	days := math.Inf(1)
+	for _, path := range c.Certs {
+		certs, err := LoadCertificates(path)
+		if err != nil {
+			return &CheckResult{State: CheckUnknown, Message: err.Error()}
+		}
+
+		for _, cert := range certs {
+			days = math.Min(days, DaysRemaining(cert))
+		}
+	}
+
+	var err error
+	var handshake time.Duration
+	remaining := c.Client.Limit
+	if c.Cold {
+		var timing *Timing
+		sent := time.Now()
+		pctx, cancel := c.Client.pingContext(ctx)
+		_, timing, err = c.Client.ColdPing(pctx, c.Client.Target)
+		cancel()
+
+		if timing != nil {
+			handshake = timing.Handshake
+			if timing.TLS != nil {
+				for _, cert := range timing.TLS.Certificates {
+					days = math.Min(days, time.Until(cert.NotAfter).Hours()/24)
+				}
+			}
+		}
+
+		// Pings that time out or are rate limited are lost like the pings of the run
+		if _, limited := c.Client.rateLimited(sent, err); limited {
+			err = nil
+		} else if perr, ok := err.(*PingError); (!ok || !perr.TLS()) && timedOut(pctx, err) {
+			err = nil
+		}
+		remaining--
+	}
+
+	if err == nil && remaining > 0 {
+		c.Client.Limit = remaining
+		err = c.Client.Run(ctx)
+	}
+
+	return c.evaluate(c.Client.Stats(), handshake, days, err)
+}
+
+// Determines the state of the check from the stats of the pings, the handshake
+// time if it was measured and the least days until a certificate expires.
+func (c *Check) evaluate(stats *Stats, handshake time.Duration, days float64, err error) *CheckResult {
+	stats.Lock()
+	sent, received := stats.Sent, stats.Received
+	stats.Unlock()
+
+	var loss float64
+	if sent > 0 {
+		loss = 100 * float64(sent-received) / float64(sent)
+	}
+	mean, p99 := stats.MeanRTT(), stats.Percentile(99)
+
+	result := &CheckResult{
+		State: CheckOK,
+		Message: fmt.Sprintf(
+			"%s %d/%d pongs, %0.1f%% loss, rtt avg %s p99 %s",
+			c.Client.Target, received, sent, loss, round(mean), round(p99),
+		),
+		Perfdata: []string{
+			perfdata("rtt", milliseconds(mean), "ms", "", "", "0"),
+			perfdata("p99", milliseconds(p99), "ms", limit(milliseconds(c.Warning.MaxP99)), limit(milliseconds(c.Critical.MaxP99)), "0"),
+			perfdata("loss", loss, "%", limit(c.Warning.MaxLoss), limit(c.Critical.MaxLoss), "0", "100"),
+		},
+	}
+
+	if handshake > 0 {
+		result.Perfdata = append(result.Perfdata, perfdata("handshake", milliseconds(handshake), "ms", "", "", "0"))
+	}
+
+	if !math.IsInf(days, 1) {
+		// Certificates are in warning when the days left fall below the limits
+		warn, crit := limit(c.WarningDays), limit(c.CriticalDays)
+		if warn != "" {
+			warn += ":"
+		}
+		if crit != "" {
+			crit += ":"
+		}
+
+		result.Perfdata = append(result.Perfdata, perfdata("cert_days", days, "", warn, crit))
+		switch {
+		case days < 0:
+			result.Escalate(CheckCritical, fmt.Sprintf("a certificate expired %.0f days ago", -days))
+		case days < c.CriticalDays:
+			result.Escalate(CheckCritical, fmt.Sprintf("a certificate expires in %.0f days", days))
+		case days < c.WarningDays:
+			result.Escalate(CheckWarning, fmt.Sprintf("a certificate expires in %.0f days", days))
+		}
+	}
+
+	switch {
+	case err != nil:
+		result.Escalate(CheckCritical, err.Error())
+	case sent > 0 && received == 0:
+		result.Escalate(CheckCritical, "no pongs received")
+	default:
+		if err := c.Critical.Check(stats); err != nil {
+			result.Escalate(CheckCritical, err.Error())
+		} else if err := c.Warning.Check(stats); err != nil {
+			result.Escalate(CheckWarning, err.Error())
+		}
+	}
+
+	return result
+}
+
+// Formats a performance data value as label=value[uom];[warn];[crit];[min];[max]
+// omitting trailing empty limits.
+func perfdata(label string, value float64, uom string, limits ...string) string {
+	data := fmt.Sprintf("%s=%.3f%s", label, value, uom)
+	if len(limits) > 0 {
+		data += ";" + strings.Join(limits, ";")
+	}
+	return strings.TrimRight(data, ";")
+}
+
+// Formats a limit of the performance data, which is empty if it is negative.
+func limit(v float64) string {
+	if v < 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Returns the duration in fractional milliseconds, keeping negative durations
+// negative so that they are left empty as performance data limits.
+func milliseconds(d time.Duration) float64 {
+	if d < 0 {
+		return -1
+	}
+	return float64(d) / float64(time.Millisecond)
+}
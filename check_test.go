@@ -0,0 +1,72 @@
+package sping
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEvaluate(t *testing.T) {
+	check := &Check{
+		Client:       &PingClient{Target: "localhost:3264"},
+		Warning:      &Thresholds{MaxLoss: 10, MaxP99: 5 * time.Millisecond, MaxOutOfOrder: -1},
+		Critical:     &Thresholds{MaxLoss: 50, MaxP99: 10 * time.Millisecond, MaxOutOfOrder: -1},
+		WarningDays:  30,
+		CriticalDays: 7,
+	}
+
+	stats := new(Stats)
+	for i := 1; i <= 4; i++ {
+		stats.Send()
+		stats.Update(time.Duration(i)*time.Millisecond, nil)
+	}
+
+	result := check.evaluate(stats, 3*time.Millisecond, 90, nil)
+	if result.State != CheckOK {
+		t.Errorf("expected the check to be ok, got %s", result)
+	}
+
+	expected := "SPING OK - localhost:3264 4/4 pongs, 0.0% loss, rtt avg 2.5ms p99 4ms | " +
+		"rtt=2.500ms;;;0 p99=4.000ms;5;10;0 loss=0.000%;10;50;0;100 handshake=3.000ms;;;0 cert_days=90.000;30:;7:"
+	if result.String() != expected {
+		t.Errorf("unexpected output\n%s\nexpected\n%s", result, expected)
+	}
+
+	// A slow pong and a certificate close to expiring are warnings
+	stats.Send()
+	stats.Update(8*time.Millisecond, nil)
+	if result = check.evaluate(stats, 0, 20, nil); result.State != CheckWarning {
+		t.Errorf("expected the check to warn, got %s", result)
+	}
+
+	if !strings.Contains(result.Message, "expires in 20 days") || !strings.Contains(result.Message, "p99 rtt 8ms exceeds 5ms") {
+		t.Errorf("expected both warnings in %q", result.Message)
+	}
+
+	// Expired certificates and failed runs are critical
+	if result = check.evaluate(stats, 0, -1, nil); result.State != CheckCritical {
+		t.Errorf("expected an expired certificate to be critical, got %s", result)
+	}
+
+	if result = check.evaluate(stats, 0, 90, errors.New("connection refused")); result.State != CheckCritical {
+		t.Errorf("expected a failed run to be critical, got %s", result)
+	}
+
+	if result = check.evaluate(new(Stats), 0, 90, nil); result.State != CheckOK {
+		t.Errorf("expected a check without pings to be ok, got %s", result)
+	}
+}
+
+func TestCheckState(t *testing.T) {
+	states := map[CheckState]string{CheckOK: "OK", CheckWarning: "WARNING", CheckCritical: "CRITICAL", CheckUnknown: "UNKNOWN"}
+	for state, name := range states {
+		if state.String() != name {
+			t.Errorf("expected state %d to be %s, got %s", state, name, state)
+		}
+	}
+
+	if CheckOK != 0 || CheckWarning != 1 || CheckCritical != 2 || CheckUnknown != 3 {
+		t.Error("check states must be the nagios plugin exit codes")
+	}
+}
@@ -6,13 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"time"
 
 	"golang.org/x/net/context"
 
 	pb "github.com/bbengfort/sping/echo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // Client Certificates
@@ -25,11 +29,28 @@ type Dailer func(target string) (*grpc.ClientConn, error)
 
 // PingClient sends echo requests to the ping server on demand.
 type PingClient struct {
-	Name       string
-	Delay      time.Duration
-	Limit      uint
-	sequence   int64
-	Connection *grpc.ClientConn
+	Name          string
+	Delay         time.Duration
+	Limit         uint                   // the number of pings to send, 0 for unlimited
+	Duration      time.Duration          // stop sending pings after this time, 0 for unlimited
+	Timeout       time.Duration          // how long to wait for each pong, 0 for no timeout
+	Scheduler     Scheduler              // when to send each ping, defaults to every Delay
+	ShowTLS       bool                   // output the negotiated TLS details of the connection
+	Certs         *CertConfig            // the certificates to connect with, the example certificates if nil
+	Policy        *TLSPolicy             // restricts the TLS parameters of new connections
+	ExpiryWarning time.Duration          // warn when certificates expire within this window
+	CertFiles     []string               // monitor the expiry of these certificates while running
+	SessionCache  tls.ClientSessionCache // shared between connections to resume sessions
+	Size          int                    // the number of payload bytes to send per ping
+	Digest        pb.Digest_Algorithm    // the payload digest for the server to verify
+	Target        string                 // the address of the server included in records
+	Prefix        string                 // prefixes every line of output if not empty, e.g. with the target
+	Recorder      *Recorder              // records every ping to a session file if not nil
+	Tracer        *Tracer                // traces every Echo call if not nil
+	sequence      int64
+	stats         *Stats
+	random        *rand.Rand
+	Connection    *grpc.ClientConn
 	pb.SecurePingClient
 }
 
@@ -37,71 +58,230 @@ type PingClient struct {
 func (c *PingClient) Next() *pb.Ping {
 	c.sequence++
 
-	return &pb.Ping{
+	ping := &pb.Ping{
 		Sender: c.Name,
 		Sseq:   c.sequence,
-		Sent:   pb.Now(),
 		Ttl:    50,
 	}
+
+	// Send a pseudo-random payload so that corruption is not masked by
+	// middleboxes that compress or deduplicate repeated bytes.
+	if c.Size > 0 {
+		if c.random == nil {
+			c.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+
+		ping.Payload = make([]byte, c.Size)
+		c.random.Read(ping.Payload)
+		ping.Digest = pb.NewDigest(c.Digest, ping.Payload)
+	}
+
+	// Stamp the sent time last so it does not include creating the payload
+	ping.Sent = pb.Now()
+	return ping
+}
+
+// Verify returns an error if the server reported that the payload of the ping
+// was corrupted or if the payload of the pong does not match its digest.
+func (c *PingClient) Verify(pong *pb.Pong) error {
+	if pong.Corrupted {
+		return fmt.Errorf("ping %d payload corrupted on the way to the server", pong.Sseq)
+	}
+
+	if !pong.Digest.Verify(pong.Payload) {
+		return fmt.Errorf("pong %d payload corrupted on the way to the client", pong.Sseq)
+	}
+
+	return nil
 }
 
-// Run the ping client against the server.
-func (c *PingClient) Run() error {
+// Returns the context for a single ping, which is cancelled after the timeout.
+func (c *PingClient) pingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout > 0 {
+		return context.WithTimeout(ctx, c.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Returns true if the ping timed out, either because its context expired or
+// because the server gave up on the call, e.g. a dropped call it held.
+func timedOut(ctx context.Context, err error) bool {
+	return ctx.Err() == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded
+}
+
+// Stats returns the statistics of the pings sent by the client.
+func (c *PingClient) Stats() *Stats {
+	if c.stats == nil {
+		c.stats = new(Stats)
+	}
+	return c.stats
+}
+
+// Output the message, prefixed if the client has a prefix.
+func (c *PingClient) output(format string, args ...interface{}) {
+	if c.Prefix != "" {
+		format = "%s: " + format
+		args = append([]interface{}{c.Prefix}, args...)
+	}
+	Output(format, args...)
+}
+
+// Run the ping client against the server until the limit of pings has been
+// sent, the duration has elapsed or the context is done. Cancelling the
+// context (e.g. with a deadline) also cancels the ping awaiting a response.
+func (c *PingClient) Run(ctx context.Context) error {
+
+	// Warn if the client certificates are close to expiring
+	if len(c.CertFiles) > 0 {
+		monitor, err := monitorCerts(c.ExpiryWarning, c.CertFiles...)
+		if err != nil {
+			return err
+		}
+		defer monitor.Stop()
+	}
 
-	var idx uint
-	ticker := time.NewTicker(c.Delay)
+	var ok bool
+	stats := c.Stats()
+	next := time.Now()
+	scheduler := c.schedule()
 
-	for range ticker.C {
-		idx++
-		if idx > c.Limit {
+	// Stops sending pings after the duration or the limit is reached
+	sending, stop := c.sending(ctx)
+	defer stop()
+
+	for idx := uint(1); ; idx++ {
+		if next, ok = wait(sending, scheduler, next); !ok {
+			for _, line := range stats.Summary() {
+				c.output("%s", line)
+			}
 			return nil
 		}
 
-		pong, err := c.Echo(context.Background(), c.Next())
+		// The local start time carries a monotonic clock reading, unlike the
+		// wall clock time echoed by the server, so clock jumps during the
+		// ping cannot produce a negative or inflated round trip time.
+		var p peer.Peer
+		stats.Send()
+		start := time.Now()
+		pctx, cancel := c.pingContext(ctx)
+		pong, err := c.tracedEcho(pctx, c.SecurePingClient, c.Next(), grpc.Peer(&p))
+		delta := time.Since(start)
+		cancel()
+
+		if idx == c.Limit {
+			stop()
+		}
+
 		if err != nil {
-			return fmt.Errorf("failed echo RPC call: %s", err)
+			// The run was stopped while awaiting the pong
+			if ctx.Err() != nil {
+				continue
+			}
+
+			// Pings that time out are lost but the run continues
+			if timedOut(pctx, err) {
+				c.record(start, 0, nil, nil, context.DeadlineExceeded)
+				c.output("ping %d timed out after %s", c.sequence, delta)
+				continue
+			}
+
+			// Pings rejected by the rate limit are lost and the run backs off
+			if delay, limited := c.rateLimited(start, err); limited {
+				if retry := time.Now().Add(delay); retry.After(next) {
+					next = retry
+				}
+				continue
+			}
+
+			c.record(start, 0, nil, nil, err)
+			return &PingError{fmt.Errorf("failed echo RPC call: %s", err)}
 		}
 
-		delta := time.Since(pong.Sent.Parse())
-		Output("ping %d/%d took %s", pong.Sseq, pong.Rseq, delta)
+		// Estimate the clock offset and one-way delays from the exchange
+		exchange := NewExchange(pong, time.Now())
+		stats.Update(delta, exchange)
+		c.record(start, delta, pong, exchange, nil)
+
+		// Corrupted pongs are tallied separately from losses
+		if err := c.Verify(pong); err != nil {
+			stats.Corrupt()
+			c.output("%s", err)
+		}
+
+		if !pong.Success {
+			stats.Misordered()
+		}
+
+		// Output the TLS details once since the connection is reused
+		if c.ShowTLS && idx == 1 {
+			outputTLS(c.output, "tls connection to", p.Addr, PeerTLSDetails(&p))
+		}
+
+		if exchange != nil {
+			c.output("ping %d/%d took %s jitter %s ipdv %s (%s)", pong.Sseq, pong.Rseq, delta, stats.Jitter, stats.IPDV, exchange)
+		} else {
+			c.output("ping %d/%d took %s jitter %s ipdv %s", pong.Sseq, pong.Rseq, delta, stats.Jitter, stats.IPDV)
+		}
+	}
+}
+
+// RunSweep runs the ping client once for each of the payload sizes, reporting
+// the round trip times per payload size when the sweep is complete. The stats
+// of the client are those of the pings of every payload size.
+func (c *PingClient) RunSweep(ctx context.Context, sizes []int) error {
+	total := c.Stats()
+	defer func() { c.stats = total }()
+
+	results := make([]*Stats, 0, len(sizes))
+	for _, size := range sizes {
+		c.output("pinging with %d byte payloads", size)
+		c.Size = size
+		c.stats = new(Stats)
+
+		err := c.Run(ctx)
+		total.Merge(c.stats)
+		if err != nil {
+			return err
+		}
+		results = append(results, c.stats)
+
+		if ctx.Err() != nil {
+			sizes = sizes[:len(results)]
+			break
+		}
+	}
+
+	c.output("payload size sweep results:")
+	for i, stats := range results {
+		c.output(
+			"  %8d bytes: rtt min/avg/max = %s/%s/%s",
+			sizes[i], stats.MinRTT, stats.MeanRTT(), stats.MaxRTT,
+		)
 	}
 
-	return errors.New("run finished unexpectedly")
+	return nil
 }
 
 // Ping sends an Ping request to the server and awaits a response.
 // Right now we create a new connection for every single ping.
 func (c *PingClient) Ping(addr string) (*pb.Pong, error) {
 
-	// Load the certificates from disk
-	certificate, err := tls.LoadX509KeyPair(ClientCert, ClientKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not load client key pair: %s", err)
-	}
-
-	// Create a certificate pool from the certificate authority
-	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(ExampleCA)
+	// Create the mutual TLS configuration
+	conf, err := mutualTLSConfig(c.Certs, c.Policy)
 	if err != nil {
-		return nil, fmt.Errorf("could not read ca certificate: %s", err)
-	}
-
-	// Append the client certificates from the CA
-	if ok := certPool.AppendCertsFromPEM(ca); !ok {
-		return nil, errors.New("failed to append ca certs")
+		return nil, err
 	}
+	conf.ClientSessionCache = c.SessionCache
 
 	// Create the TLS credentials for transport
-	creds := credentials.NewTLS(&tls.Config{
-		ServerName:   ServerName,
-		Certificates: []tls.Certificate{certificate},
-		RootCAs:      certPool,
-	})
+	creds := credentials.NewTLS(conf)
 
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	target, opts := dialTarget(addr)
+	conn, err := grpc.Dial(target, append(opts, grpc.WithTransportCredentials(creds))...)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to %s: %s", addr, err)
 	}
+	defer conn.Close()
 
 	client := pb.NewSecurePingClient(conn)
 	pong, err := client.Echo(context.Background(), c.Next())
@@ -117,17 +297,50 @@ func (c *PingClient) PingMutualTLS(addr string) (*pb.Pong, error) {
 	return c.Ping(addr)
 }
 
+// MutualTLS is a Dailer that connects to the server using mutual TLS with the
+// client certificates and example certificate authority.
 func MutualTLS(addr string) (*grpc.ClientConn, error) {
+	return MutualTLSPolicy(nil, nil)(addr)
+}
+
+// MutualTLSPolicy returns a Dailer that connects to the server using mutual
+// TLS with the certificates (or the example certificates if nil), restricting
+// the negotiated TLS parameters with the specified policy.
+func MutualTLSPolicy(certs *CertConfig, policy *TLSPolicy) Dailer {
+	return func(addr string) (*grpc.ClientConn, error) {
+
+		// Create the mutual TLS configuration
+		conf, err := mutualTLSConfig(certs, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create the TLS credentials for transport
+		creds := credentials.NewTLS(conf)
+
+		target, opts := dialTarget(addr)
+		conn, err := grpc.Dial(target, append(opts, grpc.WithTransportCredentials(creds))...)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to %s: %s", addr, err)
+		}
+		return conn, nil
+	}
+}
+
+// Creates the client TLS configuration for mutual authentication by loading
+// the client certificates and the certificate authority from disk.
+func mutualTLSConfig(certs *CertConfig, policy *TLSPolicy) (*tls.Config, error) {
+	certs = certsOrDefault(certs)
 
 	// Load the certificates from disk
-	certificate, err := tls.LoadX509KeyPair(ClientCert, ClientKey)
+	certificate, err := tls.LoadX509KeyPair(certs.ClientCert, certs.ClientKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not load client key pair: %s", err)
 	}
 
 	// Create a certificate pool from the certificate authority
 	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(ExampleCA)
+	ca, err := ioutil.ReadFile(certs.CA)
 	if err != nil {
 		return nil, fmt.Errorf("could not read ca certificate: %s", err)
 	}
@@ -137,44 +350,55 @@ func MutualTLS(addr string) (*grpc.ClientConn, error) {
 		return nil, errors.New("failed to append ca certs")
 	}
 
-	// Create the TLS credentials for transport
-	creds := credentials.NewTLS(&tls.Config{
-		ServerName:   ServerName,
+	conf := &tls.Config{
+		ServerName:   certs.ServerName,
 		Certificates: []tls.Certificate{certificate},
 		RootCAs:      certPool,
-	})
+	}
 
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
-	if err != nil {
-		return nil, fmt.Errorf("could not connect to %s: %s", addr, err)
+	// Restrict the negotiated parameters with the policy
+	if err := policy.Apply(conf); err != nil {
+		return nil, fmt.Errorf("invalid tls policy: %s", err)
 	}
-	return conn, nil
+
+	return conf, nil
 }
 
 // PingTLS is a helper method for server-side encryption that does not expect
 // client authentication or credentials. It is mostly here for benchmarking.
 func TLS(addr string) (*grpc.ClientConn, error) {
+	return TLSCerts(nil)(addr)
+}
 
-	// Create the client TLS credentials
-	creds, err := credentials.NewClientTLSFromFile(ServerCert, "")
-	if err != nil {
-		return nil, fmt.Errorf("could not load tls cert: %s", err)
-	}
+// TLSCerts returns a Dailer that connects to the server using TLS, trusting
+// the server certificate (or the example certificate if certs is nil).
+func TLSCerts(certs *CertConfig) Dailer {
+	certs = certsOrDefault(certs)
+	return func(addr string) (*grpc.ClientConn, error) {
 
-	// Create a connection with the TLS credentials
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
-	if err != nil {
-		return nil, fmt.Errorf("could not dial %s: %s", addr, err)
-	}
+		// Create the client TLS credentials
+		creds, err := credentials.NewClientTLSFromFile(certs.ServerCert, "")
+		if err != nil {
+			return nil, fmt.Errorf("could not load tls cert: %s", err)
+		}
 
-	return conn, nil
+		// Create a connection with the TLS credentials
+		target, opts := dialTarget(addr)
+		conn, err := grpc.Dial(target, append(opts, grpc.WithTransportCredentials(creds))...)
+		if err != nil {
+			return nil, fmt.Errorf("could not dial %s: %s", addr, err)
+		}
+
+		return conn, nil
+	}
 }
 
 // PingInsecure is a helper method for no server-side encryption.
 // It is mostly here for benchmarking.
 func Insecure(addr string) (*grpc.ClientConn, error) {
 	// Create an insecure connection
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	target, opts := dialTarget(addr)
+	conn, err := grpc.Dial(target, append(opts, grpc.WithInsecure())...)
 	if err != nil {
 		return nil, fmt.Errorf("could not dial %s: %s", addr, err)
 	}
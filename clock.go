@@ -0,0 +1,71 @@
+package sping
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/bbengfort/sping/echo"
+)
+
+// Exchange holds the four timestamps of an NTP-style ping exchange: the
+// client's send time (t1), the server's receive (t2) and transmit (t3) times
+// and the client's receive time (t4). The client and server clocks may be
+// offset from each other, so only differences between timestamps taken on
+// the same host are meaningful on their own.
+type Exchange struct {
+	Sent        time.Time // t1: when the client sent the ping
+	Received    time.Time // t2: when the server received the ping
+	Transmitted time.Time // t3: when the server sent the pong
+	Returned    time.Time // t4: when the client received the pong
+}
+
+// NewExchange creates the exchange from a pong and the time the client
+// received it, returning nil if the server did not stamp its times.
+func NewExchange(pong *pb.Pong, returned time.Time) *Exchange {
+	if pong.Sent == nil || pong.Received == nil || pong.Transmitted == nil {
+		return nil
+	}
+
+	return &Exchange{
+		Sent:        pong.Sent.Parse(),
+		Received:    pong.Received.Parse(),
+		Transmitted: pong.Transmitted.Parse(),
+		Returned:    returned,
+	}
+}
+
+// Offset estimates how far the server clock is ahead of the client clock,
+// assuming that the outbound and return paths have the same delay.
+func (e *Exchange) Offset() time.Duration {
+	return (e.Received.Sub(e.Sent) + e.Transmitted.Sub(e.Returned)) / 2
+}
+
+// Delay returns the round trip network delay, excluding server processing.
+func (e *Exchange) Delay() time.Duration {
+	return e.Returned.Sub(e.Sent) - e.Processing()
+}
+
+// Processing returns the time the server spent handling the ping.
+func (e *Exchange) Processing() time.Duration {
+	return e.Transmitted.Sub(e.Received)
+}
+
+// Outbound returns the one-way delay from the client to the server, corrected
+// for the estimated clock offset.
+func (e *Exchange) Outbound() time.Duration {
+	return e.Received.Sub(e.Sent) - e.Offset()
+}
+
+// Inbound returns the one-way delay from the server to the client, corrected
+// for the estimated clock offset.
+func (e *Exchange) Inbound() time.Duration {
+	return e.Returned.Sub(e.Transmitted) + e.Offset()
+}
+
+// String returns a human readable description of the exchange.
+func (e *Exchange) String() string {
+	return fmt.Sprintf(
+		"offset %s outbound %s inbound %s processing %s",
+		e.Offset(), e.Outbound(), e.Inbound(), e.Processing(),
+	)
+}
@@ -0,0 +1,36 @@
+package sping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExchange(t *testing.T) {
+	// Server clock is 50ms ahead, outbound takes 10ms, return takes 20ms
+	// and the server spends 5ms processing the ping.
+	t1 := time.Unix(1551812073, 0)
+	ex := &Exchange{
+		Sent:        t1,
+		Received:    t1.Add(60 * time.Millisecond),
+		Transmitted: t1.Add(65 * time.Millisecond),
+		Returned:    t1.Add(35 * time.Millisecond),
+	}
+
+	if offset := ex.Offset(); offset != 45*time.Millisecond {
+		t.Errorf("expected offset of 45ms got %s", offset)
+	}
+
+	if delay := ex.Delay(); delay != 30*time.Millisecond {
+		t.Errorf("expected delay of 30ms got %s", delay)
+	}
+
+	if proc := ex.Processing(); proc != 5*time.Millisecond {
+		t.Errorf("expected processing of 5ms got %s", proc)
+	}
+
+	// Asymmetric paths cannot be detected, so both directions are estimated
+	// as half of the network delay.
+	if ex.Outbound() != 15*time.Millisecond || ex.Inbound() != 15*time.Millisecond {
+		t.Errorf("expected one-way delays of 15ms got %s and %s", ex.Outbound(), ex.Inbound())
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bbengfort/sping"
+	"github.com/urfave/cli"
+)
+
+// Concatenates the flags of a command with the shared flags.
+func flags(cmd []cli.Flag, shared ...[]cli.Flag) []cli.Flag {
+	for _, group := range shared {
+		cmd = append(cmd, group...)
+	}
+	return cmd
+}
+
+// Load the config file if specified, then override its settings with any
+// environment variables or command line flags that are set. Flags take
+// precedence over environment variables, which take precedence over the
+// config file, which takes precedence over the defaults.
+func loadConfig(c *cli.Context) (conf *sping.Config, err error) {
+	if path := c.String("config"); path != "" {
+		if conf, err = sping.LoadConfig(path); err != nil {
+			return nil, err
+		}
+	} else {
+		conf = sping.DefaultConfig()
+	}
+
+	// IsSet is true if the flag or its environment variable is set
+	set := func(name string, apply func()) {
+		if c.IsSet(name) {
+			apply()
+		}
+	}
+
+	set("security", func() { conf.Security = c.String("security") })
+	set("expiry-warning", func() { conf.ExpiryWarning = c.Uint("expiry-warning") })
+	set("quiet", func() { conf.Output.Quiet = c.Bool("quiet") })
+
+	set("tls-min-version", func() { conf.TLS.MinVersion = c.String("tls-min-version") })
+	set("tls-max-version", func() { conf.TLS.MaxVersion = c.String("tls-max-version") })
+	set("tls-ciphers", func() { conf.TLS.Ciphers = strings.Split(c.String("tls-ciphers"), ",") })
+	set("tls-curves", func() { conf.TLS.Curves = strings.Split(c.String("tls-curves"), ",") })
+
+	set("fault-delay", func() { conf.Serve.Faults.Delay = c.Duration("fault-delay") })
+	set("fault-jitter", func() { conf.Serve.Faults.Jitter = c.Duration("fault-jitter") })
+	set("fault-distribution", func() { conf.Serve.Faults.Distribution = c.String("fault-distribution") })
+	set("fault-drop", func() { conf.Serve.Faults.DropRate = c.Float64("fault-drop") })
+	set("fault-error-rate", func() { conf.Serve.Faults.ErrorRate = c.Float64("fault-error-rate") })
+	set("fault-error-code", func() { conf.Serve.Faults.ErrorCode = c.String("fault-error-code") })
+	set("fault-corrupt", func() { conf.Serve.Faults.CorruptRate = c.Float64("fault-corrupt") })
+
+	set("trace", func() { conf.Tracing.Exporter = c.String("trace") })
+	set("trace-endpoint", func() { conf.Tracing.Endpoint = c.String("trace-endpoint") })
+	set("trace-service", func() { conf.Tracing.Service = c.String("trace-service") })
+
+	if c.Command.Name == "echo" || c.Command.Name == "check" {
+		set("name", func() { conf.Echo.Name = c.String("name") })
+		set("port", func() { conf.Echo.Port = c.Uint("port") })
+		set("count", func() { conf.Echo.Count = c.Uint("count") })
+		set("duration", func() { conf.Echo.Duration = c.Duration("duration") })
+		set("timeout", func() { conf.Echo.Timeout = c.Duration("timeout") })
+		set("deadline", func() { conf.Echo.Deadline = c.Duration("deadline") })
+		set("delay", func() { conf.Echo.Delay = time.Duration(c.Int64("delay")) * time.Millisecond })
+		set("schedule", func() { conf.Echo.Schedule = c.String("schedule") })
+		set("burst", func() { conf.Echo.Burst = c.Int("burst") })
+		set("cold", func() { conf.Echo.Cold = c.Bool("cold") })
+		set("resume", func() { conf.Echo.Resume = c.Bool("resume") })
+		set("size", func() { conf.Echo.Size = c.Int("size") })
+		set("digest", func() { conf.Echo.Digest = c.String("digest") })
+		set("record", func() { conf.Echo.Record = c.String("record") })
+		set("max-loss", func() { conf.Echo.MaxLoss = c.String("max-loss") })
+		set("max-p99", func() { conf.Echo.MaxP99 = c.Duration("max-p99") })
+		set("max-out-of-order", func() { conf.Echo.MaxOutOfOrder = c.Int("max-out-of-order") })
+		set("tls-info", func() { conf.Output.TLSInfo = c.Bool("tls-info") })
+		set("tui", func() { conf.Output.TUI = c.Bool("tui") })
+
+		if c.IsSet("size-sweep") {
+			if conf.Echo.SizeSweep, err = parseSizes(c.String("size-sweep")); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// The port of the other commands is the port the server listens on
+		set("port", func() { conf.Serve.Port = c.Uint("port") })
+		set("bind", func() { conf.Serve.Bind = c.String("bind") })
+		if c.Bool("ipv4") && c.Bool("ipv6") {
+			return nil, errors.New("specify only one of ipv4 or ipv6")
+		}
+
+		set("ipv4", func() { conf.Serve.Network = "tcp4" })
+		set("ipv6", func() { conf.Serve.Network = "tcp6" })
+		set("listen", func() { conf.Serve.Listeners = c.StringSlice("listen") })
+		set("unix", func() { conf.Serve.Unix = c.String("unix") })
+
+		if c.IsSet("unix-uids") {
+			if conf.Serve.UnixUIDs, err = parseUIDs(c.String("unix-uids")); err != nil {
+				return nil, err
+			}
+		}
+		set("metrics", func() { conf.Serve.Metrics = c.String("metrics") })
+		set("state", func() { conf.Serve.StateFile = c.String("state") })
+		set("snapshot-interval", func() { conf.Serve.SnapshotInterval = c.Duration("snapshot-interval") })
+		set("ticket-rotation", func() { conf.Serve.TicketRotation = c.Duration("ticket-rotation") })
+		set("reflection", func() { conf.Serve.Reflection = c.Bool("reflection") })
+		set("reflection-clients", func() { conf.Serve.ReflectionClients = strings.Split(c.String("reflection-clients"), ",") })
+		set("fault-admin", func() { conf.Serve.FaultAdmin = c.Bool("fault-admin") })
+		set("fault-admin-clients", func() { conf.Serve.FaultAdminClients = strings.Split(c.String("fault-admin-clients"), ",") })
+		set("rate-limit", func() { conf.Serve.RateLimit.Rate = c.Float64("rate-limit") })
+		set("rate-burst", func() { conf.Serve.RateLimit.Burst = c.Int("rate-burst") })
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	conf.Apply()
+	return conf, nil
+}
@@ -1,35 +1,164 @@
 package main
 
 import (
+	"crypto/x509"
+	"errors"
+	_ "expvar"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/bbengfort/sping"
+	"github.com/bbengfort/sping/echo"
 	"github.com/urfave/cli"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-// Default values for various options.
-const (
-	DefaultPort  = uint(3264)
-	DefaultPings = uint(8)
-	DefaultDelay = int64(100)
-)
+// Flags that load the configuration and select the connection security.
+var configFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "config",
+		Usage:  "load settings from a yaml config file",
+		EnvVar: "SPING_CONFIG",
+	},
+	cli.StringFlag{
+		Name:   "security",
+		Usage:  "the security of the connection: mtls (default), tls or insecure",
+		EnvVar: "SPING_SECURITY",
+	},
+}
+
+// Flags that configure the TLS policy of both the server and the client.
+var tlsPolicyFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "tls-min-version",
+		Usage:  "the minimum tls version to negotiate, e.g. 1.2 or 1.3",
+		EnvVar: "SPING_TLS_MIN_VERSION",
+	},
+	cli.StringFlag{
+		Name:   "tls-max-version",
+		Usage:  "the maximum tls version to negotiate, e.g. 1.2 or 1.3",
+		EnvVar: "SPING_TLS_MAX_VERSION",
+	},
+	cli.StringFlag{
+		Name:   "tls-ciphers",
+		Usage:  "comma separated list of tls 1.2 cipher suites to allow",
+		EnvVar: "SPING_TLS_CIPHERS",
+	},
+	cli.StringFlag{
+		Name:   "tls-curves",
+		Usage:  "comma separated list of key exchange curves, e.g. X25519,P256",
+		EnvVar: "SPING_TLS_CURVES",
+	},
+}
+
+// Flags that configure the faults injected into Echo calls by the server.
+var faultFlags = []cli.Flag{
+	cli.DurationFlag{
+		Name:   "fault-delay",
+		Usage:  "the mean delay to add to each echo call",
+		EnvVar: "SPING_FAULT_DELAY",
+	},
+	cli.DurationFlag{
+		Name:   "fault-jitter",
+		Usage:  "the spread of the added delay around the mean",
+		EnvVar: "SPING_FAULT_JITTER",
+	},
+	cli.StringFlag{
+		Name:   "fault-distribution",
+		Usage:  "the added delay distribution: constant, uniform, normal or exponential",
+		EnvVar: "SPING_FAULT_DISTRIBUTION",
+		Value:  "constant",
+	},
+	cli.Float64Flag{
+		Name:   "fault-drop",
+		Usage:  "the rate of echo calls that are never responded to, 0 to 1",
+		EnvVar: "SPING_FAULT_DROP",
+	},
+	cli.Float64Flag{
+		Name:   "fault-error-rate",
+		Usage:  "the rate of echo calls that return an error, 0 to 1",
+		EnvVar: "SPING_FAULT_ERROR_RATE",
+	},
+	cli.StringFlag{
+		Name:   "fault-error-code",
+		Usage:  "the grpc status code of injected errors, e.g. unavailable",
+		EnvVar: "SPING_FAULT_ERROR_CODE",
+		Value:  "unavailable",
+	},
+	cli.Float64Flag{
+		Name:   "fault-corrupt",
+		Usage:  "the rate of echo calls whose payload is corrupted, 0 to 1",
+		EnvVar: "SPING_FAULT_CORRUPT",
+	},
+}
 
-func signalHandler() {
+// Flags that export traces of the Echo calls of both the server and the client.
+var traceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "trace",
+		Usage:  "export spans of every echo call to stdout or otlp",
+		EnvVar: "SPING_TRACE",
+	},
+	cli.StringFlag{
+		Name:   "trace-endpoint",
+		Usage:  "the otlp/http traces endpoint of the collector",
+		Value:  sping.DefaultTraceEndpoint,
+		EnvVar: "SPING_TRACE_ENDPOINT",
+	},
+	cli.StringFlag{
+		Name:   "trace-service",
+		Usage:  "the service name of the exported spans",
+		Value:  sping.DefaultTraceService,
+		EnvVar: "SPING_TRACE_SERVICE",
+	},
+}
+
+// Calls the shutdown function when an interrupt or terminate signal is received.
+func signalHandler(shutdown func()) {
 	// Make signal channel and register notifiers for Interupt and Terminate
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, os.Interrupt)
 	signal.Notify(sigchan, syscall.SIGTERM)
 
-	// Block until we receive a signal on the channel
+	// Block until we receive a signal on the channel, then restore the default
+	// handling so that another signal exits if the graceful stop hangs
 	<-sigchan
+	signal.Stop(sigchan)
 
-	// Log the shutdown
+	// Log the shutdown and stop gracefully
 	log.Println("shutting down!")
-	os.Exit(0)
+	shutdown()
+}
+
+// Returns a context that is cancelled when an interrupt or terminate signal
+// is received so that the client can stop pinging and report its summary.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigchan:
+			log.Println("stopping pings!")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigchan)
+	}()
+
+	return ctx, cancel
 }
 
 func main() {
@@ -45,41 +174,413 @@ func main() {
 			Name:   "serve",
 			Usage:  "run the sping server",
 			Action: startServer,
-			Flags: []cli.Flag{
+			Flags: flags([]cli.Flag{
 				cli.UintFlag{
-					Name:  "p, port",
-					Usage: "specify the port to listen on",
-					Value: DefaultPort,
+					Name:   "p, port",
+					Usage:  "specify the port to listen on",
+					Value:  sping.DefaultPort,
+					EnvVar: "SPING_PORT",
+				},
+				cli.StringFlag{
+					Name:   "b, bind",
+					Usage:  "the address to listen on, all interfaces by default",
+					EnvVar: "SPING_BIND",
+				},
+				cli.BoolFlag{
+					Name:  "4, ipv4",
+					Usage: "only listen for ipv4 connections",
+				},
+				cli.BoolFlag{
+					Name:  "6, ipv6",
+					Usage: "only listen for ipv6 connections",
+				},
+				cli.StringSliceFlag{
+					Name:   "listen",
+					Usage:  "listen on [security+]network://address instead, may be repeated",
+					EnvVar: "SPING_LISTEN",
+				},
+				cli.StringFlag{
+					Name:   "unix",
+					Usage:  "also listen insecurely on a unix socket for local health probes",
+					EnvVar: "SPING_UNIX",
+				},
+				cli.StringFlag{
+					Name:   "unix-uids",
+					Usage:  "comma separated uids allowed to connect to the unix socket",
+					EnvVar: "SPING_UNIX_UIDS",
 				},
 				cli.StringFlag{
 					Name:  "n, name",
 					Usage: "specify the name of the client",
 				},
-			},
+				cli.UintFlag{
+					Name:   "expiry-warning",
+					Usage:  "warn when certificates expire within this many days",
+					Value:  sping.DefaultWarn,
+					EnvVar: "SPING_EXPIRY_WARNING",
+				},
+				cli.StringFlag{
+					Name:   "state",
+					Usage:  "persist the sequence and stats of each sender to this file",
+					EnvVar: "SPING_STATE",
+				},
+				cli.DurationFlag{
+					Name:   "snapshot-interval",
+					Usage:  "how often the sender state is saved",
+					Value:  sping.DefaultSnapshotInterval,
+					EnvVar: "SPING_SNAPSHOT_INTERVAL",
+				},
+				cli.StringFlag{
+					Name:   "metrics",
+					Usage:  "serve metrics at /debug/vars on the specified address",
+					EnvVar: "SPING_METRICS",
+				},
+				cli.DurationFlag{
+					Name:   "ticket-rotation",
+					Usage:  "rotate the tls session ticket keys at this interval",
+					EnvVar: "SPING_TICKET_ROTATION",
+				},
+				cli.BoolFlag{
+					Name:   "reflection",
+					Usage:  "register grpc reflection for debugging with grpcurl",
+					EnvVar: "SPING_REFLECTION",
+				},
+				cli.StringFlag{
+					Name:   "reflection-clients",
+					Usage:  "comma separated client certificate identities allowed to use reflection",
+					EnvVar: "SPING_REFLECTION_CLIENTS",
+				},
+				cli.BoolFlag{
+					Name:   "fault-admin",
+					Usage:  "allow the injected faults to be changed while running",
+					EnvVar: "SPING_FAULT_ADMIN",
+				},
+				cli.StringFlag{
+					Name:   "fault-admin-clients",
+					Usage:  "comma separated client certificate identities allowed to change the faults",
+					EnvVar: "SPING_FAULT_ADMIN_CLIENTS",
+				},
+				cli.Float64Flag{
+					Name:   "rate-limit",
+					Usage:  "pings per second accepted from each client identity, unlimited if zero",
+					EnvVar: "SPING_RATE_LIMIT",
+				},
+				cli.IntFlag{
+					Name:   "rate-burst",
+					Usage:  "pings accepted back to back from each client identity, defaults to the rate",
+					EnvVar: "SPING_RATE_BURST",
+				},
+				cli.BoolFlag{
+					Name:   "q, quiet",
+					Usage:  "do not log each ping received",
+					EnvVar: "SPING_QUIET",
+				},
+			}, configFlags, tlsPolicyFlags, faultFlags, traceFlags),
 		},
 		{
-			Name:   "echo",
-			Usage:  "run the sping client",
-			Action: startClient,
-			Flags: []cli.Flag{
+			Name:      "echo",
+			Usage:     "run the sping client",
+			ArgsUsage: "addr [addr...]",
+			Action:    startClient,
+			Flags: flags([]cli.Flag{
 				cli.StringFlag{
-					Name:  "n, name",
-					Usage: "specify the name of the client",
+					Name:   "n, name",
+					Usage:  "specify the name of the client",
+					EnvVar: "SPING_NAME",
+				},
+				cli.UintFlag{
+					Name:   "p, port",
+					Usage:  "specify the port to ping to",
+					Value:  sping.DefaultPort,
+					EnvVar: "SPING_PORT",
+				},
+				cli.UintFlag{
+					Name:   "c, count, l, limit",
+					Usage:  "specify the max number of pings to send, 0 for unlimited",
+					Value:  sping.DefaultPings,
+					EnvVar: "SPING_COUNT",
+				},
+				cli.DurationFlag{
+					Name:   "duration",
+					Usage:  "stop sending pings after this amount of time, e.g. 10m",
+					EnvVar: "SPING_DURATION",
+				},
+				cli.DurationFlag{
+					Name:   "t, timeout",
+					Usage:  "how long to wait for each pong before it is lost",
+					EnvVar: "SPING_TIMEOUT",
+				},
+				cli.DurationFlag{
+					Name:   "w, deadline",
+					Usage:  "exit after this amount of time regardless of pings outstanding",
+					EnvVar: "SPING_DEADLINE",
+				},
+				cli.Int64Flag{
+					Name:   "d, delay",
+					Usage:  "the delay between pings in milliseconds",
+					Value:  int64(sping.DefaultDelay / time.Millisecond),
+					EnvVar: "SPING_DELAY",
+				},
+				cli.StringFlag{
+					Name:   "schedule",
+					Usage:  "when to send pings: fixed, poisson (mean delay) or burst",
+					Value:  "fixed",
+					EnvVar: "SPING_SCHEDULE",
+				},
+				cli.IntFlag{
+					Name:   "burst",
+					Usage:  "the number of pings sent back to back every delay in a burst",
+					Value:  sping.DefaultBurstSize,
+					EnvVar: "SPING_BURST",
+				},
+				cli.BoolFlag{
+					Name:   "cold",
+					Usage:  "use a new mutual TLS connection per ping and time the setup phases",
+					EnvVar: "SPING_COLD",
+				},
+				cli.BoolFlag{
+					Name:   "tls-info",
+					Usage:  "print the negotiated tls details of each connection",
+					EnvVar: "SPING_TLS_INFO",
+				},
+				cli.BoolFlag{
+					Name:   "resume",
+					Usage:  "share a tls session cache to resume cold connections",
+					EnvVar: "SPING_RESUME",
+				},
+				cli.IntFlag{
+					Name:   "s, size",
+					Usage:  "the number of payload bytes to send with each ping",
+					EnvVar: "SPING_SIZE",
+				},
+				cli.StringFlag{
+					Name:   "size-sweep",
+					Usage:  "comma separated payload sizes to ping with in turn",
+					EnvVar: "SPING_SIZE_SWEEP",
+				},
+				cli.StringFlag{
+					Name:   "digest",
+					Usage:  "payload digest for integrity checks: crc32c or sha256",
+					EnvVar: "SPING_DIGEST",
+				},
+				cli.StringFlag{
+					Name:   "max-loss",
+					Usage:  "exit 3 if more than this percentage of pings are lost, e.g. 5%",
+					EnvVar: "SPING_MAX_LOSS",
+				},
+				cli.DurationFlag{
+					Name:   "max-p99",
+					Usage:  "exit 3 if the 99th percentile round trip time exceeds this, e.g. 50ms",
+					EnvVar: "SPING_MAX_P99",
+				},
+				cli.IntFlag{
+					Name:   "max-out-of-order",
+					Usage:  "exit 3 if more than this many pings are out of order, negative to not check",
+					Value:  -1,
+					EnvVar: "SPING_MAX_OUT_OF_ORDER",
+				},
+				cli.BoolFlag{
+					Name:   "tui",
+					Usage:  "draw a live dashboard of the stats of each target",
+					EnvVar: "SPING_TUI",
+				},
+				cli.StringFlag{
+					Name:   "record",
+					Usage:  "write every ping and its outcome to a session file",
+					EnvVar: "SPING_RECORD",
 				},
 				cli.UintFlag{
-					Name:  "p, port",
-					Usage: "specify the port to ping to",
-					Value: DefaultPort,
+					Name:   "expiry-warning",
+					Usage:  "warn when certificates expire within this many days",
+					Value:  sping.DefaultWarn,
+					EnvVar: "SPING_EXPIRY_WARNING",
+				},
+				cli.BoolFlag{
+					Name:   "q, quiet",
+					Usage:  "suppress all log output",
+					EnvVar: "SPING_QUIET",
 				},
+			}, configFlags, tlsPolicyFlags, traceFlags),
+		},
+		{
+			Name:      "faults",
+			Usage:     "get or set the faults injected by a running server",
+			ArgsUsage: "addr",
+			Action:    setFaults,
+			Flags: flags([]cli.Flag{
 				cli.UintFlag{
-					Name:  "l, limit",
-					Usage: "specify the max number of pings to send",
-					Value: DefaultPings,
+					Name:   "p, port",
+					Usage:  "specify the port of the server",
+					Value:  sping.DefaultPort,
+					EnvVar: "SPING_PORT",
+				},
+				cli.BoolFlag{
+					Name:  "set",
+					Usage: "replace the faults of the server with the fault flags",
+				},
+			}, configFlags, tlsPolicyFlags, faultFlags),
+		},
+		{
+			Name:      "check",
+			Usage:     "ping a server as a nagios or icinga plugin",
+			ArgsUsage: "addr",
+			Action:    checkServer,
+			Flags: flags([]cli.Flag{
+				cli.StringFlag{
+					Name:   "n, name",
+					Usage:  "specify the name of the client",
+					EnvVar: "SPING_NAME",
+				},
+				cli.UintFlag{
+					Name:   "p, port",
+					Usage:  "specify the port to ping to",
+					Value:  sping.DefaultPort,
+					EnvVar: "SPING_PORT",
+				},
+				cli.UintFlag{
+					Name:   "c, count",
+					Usage:  "specify the number of pings to send",
+					Value:  sping.DefaultPings,
+					EnvVar: "SPING_COUNT",
+				},
+				cli.DurationFlag{
+					Name:   "t, timeout",
+					Usage:  "how long to wait for each pong before it is lost",
+					EnvVar: "SPING_TIMEOUT",
 				},
 				cli.Int64Flag{
-					Name:  "d, delay",
-					Usage: "the delay between pings in milliseconds",
-					Value: DefaultDelay,
+					Name:   "d, delay",
+					Usage:  "the delay between pings in milliseconds",
+					Value:  int64(sping.DefaultDelay / time.Millisecond),
+					EnvVar: "SPING_DELAY",
+				},
+				cli.StringFlag{
+					Name:  "warning-loss",
+					Usage: "warning if more than this percentage of pings are lost",
+				},
+				cli.StringFlag{
+					Name:  "critical-loss",
+					Usage: "critical if more than this percentage of pings are lost",
+				},
+				cli.DurationFlag{
+					Name:  "warning-p99",
+					Usage: "warning if the 99th percentile round trip time exceeds this",
+				},
+				cli.DurationFlag{
+					Name:  "critical-p99",
+					Usage: "critical if the 99th percentile round trip time exceeds this",
+				},
+				cli.Float64Flag{
+					Name:  "warning-days",
+					Usage: "warning if a certificate expires within this many days",
+					Value: float64(sping.DefaultWarn),
+				},
+				cli.Float64Flag{
+					Name:  "critical-days",
+					Usage: "critical if a certificate expires within this many days",
+					Value: 7,
+				},
+			}, configFlags, tlsPolicyFlags),
+		},
+		{
+			Name:      "health",
+			Usage:     "check the grpc health of a running server",
+			ArgsUsage: "addr",
+			Action:    checkHealth,
+			Flags: flags([]cli.Flag{
+				cli.UintFlag{
+					Name:   "p, port",
+					Usage:  "specify the port of the server",
+					Value:  sping.DefaultPort,
+					EnvVar: "SPING_PORT",
+				},
+				cli.StringFlag{
+					Name:  "service",
+					Usage: "the service to check, empty for the whole server",
+					Value: sping.HealthService,
+				},
+				cli.DurationFlag{
+					Name:  "t, timeout",
+					Usage: "how long to wait for the health check",
+					Value: 5 * time.Second,
+				},
+			}, configFlags, tlsPolicyFlags),
+		},
+		{
+			Name:   "check-certs",
+			Usage:  "validate the certificate chains, keys and SANs",
+			Action: checkCerts,
+			Flags: flags([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "s, server",
+					Usage: "only check the server certificates",
+				},
+				cli.BoolFlag{
+					Name:  "c, client",
+					Usage: "only check the client certificates",
+				},
+				cli.StringFlag{
+					Name:  "hostname",
+					Usage: "the hostname the server certificate must be valid for",
+					Value: sping.ServerName,
+				},
+				cli.UintFlag{
+					Name:   "expiry-warning",
+					Usage:  "fail if certificates expire within this many days",
+					Value:  sping.DefaultWarn,
+					EnvVar: "SPING_EXPIRY_WARNING",
+				},
+			}, configFlags),
+		},
+		{
+			Name:      "analyze",
+			Usage:     "compute statistics of a recorded echo session",
+			ArgsUsage: "session.jsonl",
+			Action:    analyze,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "only pings sent from this RFC3339 time or offset into the session",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "only pings sent before this RFC3339 time or offset into the session",
+				},
+				cli.StringFlag{
+					Name:  "result",
+					Usage: "comma separated results: ok, out_of_order, corrupted, timeout, rate_limited, error",
+				},
+				cli.StringFlag{
+					Name:  "target",
+					Usage: "only pings to this target",
+				},
+				cli.IntFlag{
+					Name:  "bins",
+					Usage: "the number of bins of the latency histogram",
+					Value: 10,
+				},
+				cli.IntFlag{
+					Name:  "windows",
+					Usage: "the number of windows of the latency timeline",
+					Value: 20,
+				},
+				cli.IntFlag{
+					Name:  "width",
+					Usage: "the width of the histogram and timeline bars",
+					Value: 50,
+				},
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "manage sping configuration files",
+			Subcommands: []cli.Command{
+				{
+					Name:      "validate",
+					Usage:     "check that a config file and its certificates are valid",
+					ArgsUsage: "path",
+					Action:    validateConfig,
+					Flags:     configFlags,
 				},
 			},
 		},
@@ -92,45 +593,695 @@ func main() {
 
 // Run the ping server
 func startServer(c *cli.Context) error {
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
-	go signalHandler()
+	policy, err := conf.Policy()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	// Serve the expvar metrics if requested
+	if addr := conf.Serve.Metrics; addr != "" {
+		go func() {
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Printf("could not serve metrics: %s", err)
+			}
+		}()
+	}
 
 	server := sping.NewServer()
-	err := server.Serve(c.Uint("port"))
+	go signalHandler(server.Shutdown)
+	server.Certs = &conf.Certs
+	server.Policy = policy
+	server.ExpiryWarning = days(conf.ExpiryWarning)
+	server.TicketKeys = conf.Serve.TicketRotation
+	server.Reflection = conf.Serve.Reflection
+	server.Reflectors = conf.Serve.ReflectionClients
+	server.UnixUIDs = conf.Serve.UnixUIDs
+	server.StateFile = conf.Serve.StateFile
+	server.Snapshots = conf.Serve.SnapshotInterval
 
+	// Export the spans of every Echo call until the server is shut down
+	if server.Tracer, err = conf.Tracer(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	server.Tracer.Start()
+	defer func() {
+		if err := server.Tracer.Stop(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if server.RateLimit, err = conf.RateLimiter(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	// Inject faults if any are specified or if they can be changed later
+	faults, err := conf.Faults()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if faults.Enabled() || conf.Serve.FaultAdmin {
+		if server.Faults, err = sping.NewFaultInjector(faults); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		server.FaultAdmin = conf.Serve.FaultAdmin
+		server.FaultAdmins = conf.Serve.FaultAdminClients
+	}
+
+	listeners, err := conf.Listeners()
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 
+	if err = server.ServeListeners(listeners...); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
 	return nil
 }
 
 // Run the ping client
 func startClient(c *cli.Context) error {
-	go signalHandler()
-	var err error
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
-	// Get the addr to ping to with the associated port
-	if c.NArg() != 1 {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// Stop pinging and cancel outstanding pings after the deadline
+	if deadline := conf.Echo.Deadline; deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	// Ping the addresses in the arguments or the targets in the config
+	targets := []string(c.Args())
+	if len(targets) == 0 {
+		targets = conf.Echo.Targets
+	}
+
+	if len(targets) == 0 {
 		return cli.NewExitError("specify an address to ping to", 1)
 	}
-	addr := fmt.Sprintf("%s:%d", c.Args()[0], c.Uint("port"))
 
 	// Get the hostname if no name is specified
-	name := c.String("name")
-	if name == "" {
-		if name, err = os.Hostname(); err != nil {
+	if conf.Echo.Name == "" {
+		if conf.Echo.Name, err = os.Hostname(); err != nil {
 			return cli.NewExitError("no hostname for the pinger", 1)
 		}
 	}
 
+	// Record the pings of all of the targets to the same session file
+	shared := new(sharedClient)
+	if conf.Echo.Record != "" {
+		if shared.recorder, err = sping.NewRecorder(conf.Echo.Record); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	// Trace the pings of all of the targets with the same tracer
+	if shared.tracer, err = conf.Tracer(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	shared.tracer.Start()
+
+	// Draw the stats of all of the targets instead of logging every ping
+	if conf.Output.TUI {
+		addrs := make([]string, 0, len(targets))
+		for _, target := range targets {
+			addrs = append(addrs, conf.Target(target))
+		}
+
+		shared.dashboard = sping.NewDashboard(os.Stdout, addrs...)
+		shared.dashboard.Start()
+	}
+
+	// Prefix the output of each target with its address to tell them apart
+	shared.prefix = len(targets) > 1 && shared.dashboard == nil
+
+	// Ping all of the targets concurrently
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for i, target := range targets {
+		if shared.prefix {
+			log.Printf("pinging %s", conf.Target(target))
+		}
+
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			errs[i] = runClient(ctx, conf, addr, shared)
+		}(i, conf.Target(target))
+	}
+	wg.Wait()
+
+	if err = shared.close(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	// Exit with the code of the first target that failed, logging the others
+	var exit error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if exit != nil {
+			log.Println(err)
+			continue
+		}
+
+		if _, ok := err.(cli.ExitCoder); ok {
+			exit = err
+		} else {
+			exit = cli.NewExitError(err.Error(), exitError)
+		}
+	}
+	return exit
+}
+
+// The outputs of the clients that are shared between all of the targets.
+type sharedClient struct {
+	recorder  *sping.Recorder  // records every ping if not nil
+	dashboard *sping.Dashboard // draws the stats of each target if not nil
+	tracer    *sping.Tracer    // traces every ping if not nil
+	prefix    bool             // prefix the output of each client with its target
+}
+
+// Watches the stats of the client on the dashboard, sets its recorder and
+// tracer to the shared outputs and prefixes its output with the address.
+func (s *sharedClient) attach(client *sping.PingClient, addr string) {
+	client.Recorder = s.recorder
+	client.Tracer = s.tracer
+	if s.prefix {
+		client.Prefix = addr
+	}
+	if s.dashboard != nil {
+		s.dashboard.Watch(addr, client.Stats())
+	}
+}
+
+// Stops the dashboard, exports the last spans and closes the session file.
+func (s *sharedClient) close() error {
+	if s.dashboard != nil {
+		s.dashboard.Stop()
+	}
+
+	if err := s.tracer.Stop(); err != nil {
+		log.Println(err)
+	}
+
+	if s.recorder != nil {
+		return s.recorder.Close()
+	}
+	return nil
+}
+
+// Ping the address until the run is complete
+func runClient(ctx context.Context, conf *sping.Config, addr string, shared *sharedClient) error {
+	// Restrict the negotiated TLS parameters
+	policy, err := conf.Policy()
+	if err != nil {
+		return err
+	}
+
+	// Verify the integrity of payloads with the digest algorithm
+	digest, err := echo.ParseAlgorithm(conf.Echo.Digest)
+	if err != nil {
+		return err
+	}
+
+	// Schedule the pings with the delay as the interval or mean
+	scheduler, err := conf.Scheduler()
+	if err != nil {
+		return err
+	}
+
+	// Cold pings create a new connection for every ping, so do not dial.
+	if conf.Echo.Cold {
+		if conf.Security != sping.SecurityMutualTLS {
+			return errors.New("cold pings require mutual tls")
+		}
+
+		if !conf.MutualTLS(addr) {
+			return errors.New("cold pings cannot use unix sockets, which are insecure")
+		}
+
+		client := &sping.PingClient{
+			Name:          conf.Echo.Name,
+			Delay:         conf.Echo.Delay,
+			Limit:         conf.Echo.Count,
+			Duration:      conf.Echo.Duration,
+			Timeout:       conf.Echo.Timeout,
+			Scheduler:     scheduler,
+			ShowTLS:       conf.Output.TLSInfo,
+			Certs:         &conf.Certs,
+			Policy:        policy,
+			ExpiryWarning: days(conf.ExpiryWarning),
+			CertFiles:     []string{conf.Certs.ClientCert, conf.Certs.CA},
+			Size:          conf.Echo.Size,
+			Digest:        digest,
+		}
+
+		if conf.Echo.Resume {
+			client.SessionCache = sping.NewSessionCache()
+		}
+
+		shared.attach(client, addr)
+		return runExit(conf, addr, client.Stats(), client.RunCold(ctx, addr))
+	}
+
+	dailer, err := conf.Dailer(addr)
+	if err != nil {
+		return err
+	}
+
 	// Create the client to start pinging to.
-	client := sping.NewClient(sping.MutualTLS, addr, name, c.Int64("delay"), c.Uint("limit"))
+	client := sping.NewClient(dailer, addr, conf.Echo.Name, int64(conf.Echo.Delay/time.Millisecond), conf.Echo.Count)
 	defer client.Connection.Close()
+	client.Delay = conf.Echo.Delay
+	client.Scheduler = scheduler
+	client.Duration = conf.Echo.Duration
+	client.Timeout = conf.Echo.Timeout
+	client.ShowTLS = conf.Output.TLSInfo
+	client.ExpiryWarning = days(conf.ExpiryWarning)
+	client.Size = conf.Echo.Size
+	client.Digest = digest
+	shared.attach(client, addr)
+
+	// Warn if the client certificates used to connect are close to expiring
+	if conf.MutualTLS(addr) {
+		client.CertFiles = []string{conf.Certs.ClientCert, conf.Certs.CA}
+	}
+
+	// Ping with each payload size in turn if a sweep is specified
+	if len(conf.Echo.SizeSweep) > 0 {
+		err = client.RunSweep(ctx, conf.Echo.SizeSweep)
+		return runExit(conf, addr, client.Stats(), err)
+	}
+
+	return runExit(conf, addr, client.Stats(), client.Run(ctx))
+}
+
+// Exit codes of the echo command so that scripts can tell failures apart.
+const (
+	exitError       = 1 // the arguments or configuration are invalid
+	exitUnreachable = 2 // a target could not be pinged or returned no pongs
+	exitThreshold   = 3 // the stats of a target breached a threshold
+	exitTLS         = 4 // the TLS handshake with a target failed
+)
+
+// Returns the exit error of the run pinging the address, classifying why the
+// run failed or checking the stats of the run against the thresholds.
+func runExit(conf *sping.Config, addr string, stats *sping.Stats, err error) error {
+	if err != nil {
+		if perr, ok := err.(*sping.PingError); ok {
+			if perr.TLS() {
+				return cli.NewExitError(fmt.Sprintf("%s: %s", addr, err), exitTLS)
+			}
+			return cli.NewExitError(fmt.Sprintf("%s: %s", addr, err), exitUnreachable)
+		}
+		return cli.NewExitError(err.Error(), exitError)
+	}
+
+	if stats.Sent > 0 && stats.Lost() == stats.Sent {
+		return cli.NewExitError(fmt.Sprintf("%s: no pongs received", addr), exitUnreachable)
+	}
+
+	thresholds, err := conf.Thresholds()
+	if err != nil {
+		return cli.NewExitError(err.Error(), exitError)
+	}
+
+	if err = thresholds.Check(stats); err != nil {
+		return cli.NewExitError(fmt.Sprintf("%s: %s", addr, err), exitThreshold)
+	}
+	return nil
+}
+
+// Recompute the statistics of a recorded session and draw its latencies.
+func analyze(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.NewExitError("specify the session file to analyze", 1)
+	}
+
+	for _, name := range []string{"bins", "windows", "width"} {
+		if c.Int(name) < 1 {
+			return cli.NewExitError(fmt.Sprintf("--%s must be positive", name), 1)
+		}
+	}
+
+	records, err := sping.LoadRecords(c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
-	if err = client.Run(); err != nil {
+	if len(records) == 0 {
+		return cli.NewExitError("no pings in the session", 1)
+	}
+
+	// Offsets are relative to the first ping of the session
+	start := sping.FilterRecords(records, nil)[0].Sent
+	filter := &sping.RecordFilter{Target: c.String("target")}
+	if filter.From, err = parseTime(c.String("from"), start); err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 
+	if filter.To, err = parseTime(c.String("to"), start); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if c.IsSet("result") {
+		filter.Results = strings.Split(c.String("result"), ",")
+	}
+
+	records = sping.FilterRecords(records, filter)
+	if len(records) == 0 {
+		return cli.NewExitError("no pings match the filter", 1)
+	}
+
+	for _, line := range sping.RecordStats(records).Summary() {
+		fmt.Println(line)
+	}
+
+	fmt.Println("\nlatency histogram:")
+	for _, line := range sping.Histogram(records, c.Int("bins"), c.Int("width")) {
+		fmt.Println(line)
+	}
+
+	fmt.Println("\nlatency timeline:")
+	for _, line := range sping.Timeline(records, c.Int("windows"), c.Int("width")) {
+		fmt.Println(line)
+	}
 	return nil
 }
+
+// Check a server as a nagios or icinga plugin, printing a single line with
+// the state and performance data and exiting with the code of the state.
+func checkServer(c *cli.Context) error {
+	unknown := func(err error) error {
+		fmt.Println(&sping.CheckResult{State: sping.CheckUnknown, Message: err.Error()})
+		return cli.NewExitError("", int(sping.CheckUnknown))
+	}
+
+	if c.NArg() != 1 {
+		return unknown(errors.New("specify the address of the server"))
+	}
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return unknown(err)
+	}
+
+	if conf.Echo.Count == 0 {
+		return unknown(errors.New("specify the number of pings to send"))
+	}
+
+	// Nothing but the result is printed
+	conf.Output.Quiet = true
+	conf.Apply()
+
+	if conf.Echo.Name == "" {
+		if conf.Echo.Name, err = os.Hostname(); err != nil {
+			return unknown(errors.New("no hostname for the pinger"))
+		}
+	}
+
+	warning, critical := sping.NoThresholds(), sping.NoThresholds()
+	if warning.MaxLoss, err = sping.ParsePercent(c.String("warning-loss")); err != nil {
+		return unknown(err)
+	}
+
+	if critical.MaxLoss, err = sping.ParsePercent(c.String("critical-loss")); err != nil {
+		return unknown(err)
+	}
+
+	if c.IsSet("warning-p99") {
+		warning.MaxP99 = c.Duration("warning-p99")
+	}
+
+	if c.IsSet("critical-p99") {
+		critical.MaxP99 = c.Duration("critical-p99")
+	}
+
+	policy, err := conf.Policy()
+	if err != nil {
+		return unknown(err)
+	}
+
+	addr := conf.Target(c.Args()[0])
+	dailer, err := conf.Dailer(addr)
+	if err != nil {
+		return unknown(err)
+	}
+
+	client := sping.NewClient(dailer, addr, conf.Echo.Name, int64(conf.Echo.Delay/time.Millisecond), conf.Echo.Count)
+	defer client.Connection.Close()
+	client.Delay = conf.Echo.Delay
+	client.Timeout = conf.Echo.Timeout
+	client.Certs = &conf.Certs
+	client.Policy = policy
+
+	check := &sping.Check{
+		Client:       client,
+		Warning:      warning,
+		Critical:     critical,
+		WarningDays:  c.Float64("warning-days"),
+		CriticalDays: c.Float64("critical-days"),
+	}
+
+	// Time the handshake and inspect the certificates of the mutual TLS setup
+	if conf.MutualTLS(addr) {
+		check.Cold = true
+		check.Certs = []string{conf.Certs.ClientCert, conf.Certs.CA}
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	result := check.Run(ctx)
+	fmt.Println(result)
+	if result.State != sping.CheckOK {
+		return cli.NewExitError("", int(result.State))
+	}
+	return nil
+}
+
+// Get the faults injected by a running server, replacing them if requested.
+func setFaults(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.NewExitError("specify the address of the server", 1)
+	}
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	conn, err := dial(conf, c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reply *echo.Faults
+	admin := echo.NewFaultInjectionClient(conn)
+	if c.Bool("set") {
+		faults, err := conf.Faults()
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		if reply, err = admin.SetFaults(ctx, faults.Proto()); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	} else {
+		if reply, err = admin.GetFaults(ctx, &echo.Empty{}); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	fmt.Println(sping.FaultsFromProto(reply))
+	return nil
+}
+
+// Check the health of a running server, exiting non-zero unless serving.
+func checkHealth(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.NewExitError("specify the address of the server", 1)
+	}
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	conn, err := dial(conf, c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	status, err := sping.CheckHealth(ctx, conn, c.String("service"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+
+	fmt.Println(status)
+	if status != healthpb.HealthCheckResponse_SERVING {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// Check the server and client certificates, exiting non-zero on failure.
+func checkCerts(c *cli.Context) error {
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	warning := days(conf.ExpiryWarning)
+	server, client := c.Bool("server"), c.Bool("client")
+	if !server && !client {
+		server, client = true, true
+	}
+
+	hostname := conf.Certs.ServerName
+	if c.IsSet("hostname") {
+		hostname = c.String("hostname")
+	}
+
+	var checks []sping.CertCheck
+	if server {
+		checks = append(checks, sping.CheckCertificates(
+			conf.Certs.ServerCert, conf.Certs.ServerKey, conf.Certs.CA,
+			hostname, x509.ExtKeyUsageServerAuth, warning,
+		)...)
+	}
+
+	if client {
+		checks = append(checks, sping.CheckCertificates(
+			conf.Certs.ClientCert, conf.Certs.ClientKey, conf.Certs.CA,
+			"", x509.ExtKeyUsageClientAuth, warning,
+		)...)
+	}
+
+	for _, check := range checks {
+		if check.Err != nil {
+			fmt.Printf("FAIL %s: %s\n", check.Name, check.Err)
+		} else {
+			fmt.Printf("ok   %s\n", check.Name)
+		}
+	}
+
+	if err := sping.CertChecksFailed(checks); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+// Validate a config file and check that its certificates exist.
+func validateConfig(c *cli.Context) error {
+	path := c.String("config")
+	if c.NArg() > 0 {
+		path = c.Args()[0]
+	}
+
+	if path == "" {
+		return cli.NewExitError("specify the path of the config file", 1)
+	}
+
+	conf, err := sping.LoadConfig(path)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if err = conf.Validate(); err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid config %s: %s", path, err), 1)
+	}
+
+	if err = conf.CheckFiles(); err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid config %s: %s", path, err), 1)
+	}
+
+	fmt.Printf("%s is valid\n", path)
+	return nil
+}
+
+// Dial the server at the host on the serve port of the config, or on the
+// unix socket if the host is a unix:// address.
+func dial(conf *sping.Config, host string) (*grpc.ClientConn, error) {
+	addr := host
+	if !strings.HasPrefix(host, "unix://") {
+		addr = net.JoinHostPort(host, strconv.FormatUint(uint64(conf.Serve.Port), 10))
+	}
+
+	dailer, err := conf.Dailer(addr)
+	if err != nil {
+		return nil, err
+	}
+	return dailer(addr)
+}
+
+// Parse a comma separated list of user ids.
+func parseUIDs(s string) ([]uint32, error) {
+	var uids []uint32
+	for _, field := range strings.Split(s, ",") {
+		uid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid %q", field)
+		}
+		uids = append(uids, uint32(uid))
+	}
+	return uids, nil
+}
+
+// Parse an RFC3339 time or a duration offset from the start of the session,
+// returning the zero time if s is empty.
+func parseTime(s string, start time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if offset, err := time.ParseDuration(s); err == nil {
+		return start.Add(offset), nil
+	}
+
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse %q as a time or offset", s)
+	}
+	return ts, nil
+}
+
+// Parse a comma separated list of payload sizes.
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid payload size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// Convert a number of days into a duration.
+func days(n uint) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
@@ -0,0 +1,477 @@
+package sping
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/bbengfort/sping/echo"
+	"gopkg.in/yaml.v2"
+)
+
+// Default values for various options.
+const (
+	DefaultPort  = uint(3264)
+	DefaultPings = uint(8)
+	DefaultDelay = 100 * time.Millisecond
+	DefaultWarn  = uint(30)
+)
+
+// Config describes the settings of the serve and echo commands so that they
+// can be loaded from a YAML file. Durations are strings such as "100ms".
+type Config struct {
+	Security      string        `yaml:"security"`       // one of mtls, tls or insecure
+	ExpiryWarning uint          `yaml:"expiry_warning"` // days before certificates expire to warn
+	Certs         CertConfig    `yaml:"certs"`
+	TLS           TLSConfig     `yaml:"tls"`
+	Serve         ServeConfig   `yaml:"serve"`
+	Echo          EchoConfig    `yaml:"echo"`
+	Output        OutputConfig  `yaml:"output"`
+	Tracing       TracingConfig `yaml:"tracing"`
+}
+
+// CertConfig specifies the paths of the certificates and keys.
+type CertConfig struct {
+	ServerCert string `yaml:"server_cert"`
+	ServerKey  string `yaml:"server_key"`
+	ServerName string `yaml:"server_name"` // the name the server certificate is valid for
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+	CA         string `yaml:"ca"`
+}
+
+// DefaultCerts returns the paths of the example certificates and keys.
+func DefaultCerts() *CertConfig {
+	return &CertConfig{
+		ServerCert: ServerCert,
+		ServerKey:  ServerKey,
+		ServerName: ServerName,
+		ClientCert: ClientCert,
+		ClientKey:  ClientKey,
+		CA:         ExampleCA,
+	}
+}
+
+// Returns the certificates, or the example certificates if they are nil.
+func certsOrDefault(certs *CertConfig) *CertConfig {
+	if certs == nil {
+		return DefaultCerts()
+	}
+	return certs
+}
+
+// TLSConfig specifies the TLS policy of both the client and the server.
+type TLSConfig struct {
+	MinVersion string   `yaml:"min_version"`
+	MaxVersion string   `yaml:"max_version"`
+	Ciphers    []string `yaml:"ciphers"`
+	Curves     []string `yaml:"curves"`
+}
+
+// ServeConfig specifies the settings of the ping server.
+type ServeConfig struct {
+	Port              uint          `yaml:"port"`
+	Bind              string        `yaml:"bind"`       // the host to bind, all interfaces if empty
+	Network           string        `yaml:"network"`    // tcp for IPv4 and IPv6, tcp4 or tcp6
+	Listeners         []string      `yaml:"listeners"`  // serve on these instead of bind:port
+	Unix              string        `yaml:"unix"`       // also serve insecurely on this unix socket
+	UnixUIDs          []uint32      `yaml:"unix_uids"`  // users allowed to connect to unix sockets
+	Metrics           string        `yaml:"metrics"`    // address to serve /debug/vars on
+	StateFile         string        `yaml:"state_file"` // persist the per-sender state to this file
+	SnapshotInterval  time.Duration `yaml:"snapshot_interval"`
+	TicketRotation    time.Duration `yaml:"ticket_rotation"`
+	Reflection        bool          `yaml:"reflection"`
+	ReflectionClients []string      `yaml:"reflection_clients"`
+	FaultAdmin        bool          `yaml:"fault_admin"`
+	FaultAdminClients []string      `yaml:"fault_admin_clients"`
+	Faults            FaultConfig   `yaml:"faults"`
+	RateLimit         RateConfig    `yaml:"rate_limit"`
+}
+
+// FaultConfig specifies the faults injected by the server.
+type FaultConfig struct {
+	Delay        time.Duration `yaml:"delay"`
+	Jitter       time.Duration `yaml:"jitter"`
+	Distribution string        `yaml:"distribution"`
+	DropRate     float64       `yaml:"drop_rate"`
+	ErrorRate    float64       `yaml:"error_rate"`
+	ErrorCode    string        `yaml:"error_code"`
+	CorruptRate  float64       `yaml:"corrupt_rate"`
+}
+
+// RateConfig specifies the rate limit of the pings of each client identity.
+type RateConfig struct {
+	Rate     float64            `yaml:"rate"`  // pings per second, unlimited if zero
+	Burst    int                `yaml:"burst"` // defaults to the rate rounded up
+	Policies []RatePolicyConfig `yaml:"policies"`
+}
+
+// RatePolicyConfig specifies the rate limit of specific client identities.
+type RatePolicyConfig struct {
+	Identities []string `yaml:"identities"` // certificate identities, uid:N or senders
+	Rate       float64  `yaml:"rate"`
+	Burst      int      `yaml:"burst"`
+}
+
+// EchoConfig specifies the settings of the ping client.
+type EchoConfig struct {
+	Name      string        `yaml:"name"`    // defaults to the hostname
+	Port      uint          `yaml:"port"`    // the port of targets without one
+	Targets   []string      `yaml:"targets"` // the addresses to ping concurrently
+	Count     uint          `yaml:"count"`
+	Duration  time.Duration `yaml:"duration"`
+	Deadline  time.Duration `yaml:"deadline"`
+	Timeout   time.Duration `yaml:"timeout"`
+	Delay     time.Duration `yaml:"delay"`
+	Schedule  string        `yaml:"schedule"`
+	Burst     int           `yaml:"burst"`
+	Cold      bool          `yaml:"cold"`
+	Resume    bool          `yaml:"resume"`
+	Size      int           `yaml:"size"`
+	SizeSweep []int         `yaml:"size_sweep"`
+	Digest    string        `yaml:"digest"`
+	Record    string        `yaml:"record"` // write every ping to this session file
+
+	// Thresholds of the stats of each target, exiting non-zero if breached
+	MaxLoss       string        `yaml:"max_loss"` // a percentage such as 5%
+	MaxP99        time.Duration `yaml:"max_p99"`
+	MaxOutOfOrder int           `yaml:"max_out_of_order"` // negative to not check
+}
+
+// OutputConfig specifies what the commands log.
+type OutputConfig struct {
+	Quiet   bool `yaml:"quiet"`    // suppress the log messages of the client and server
+	TLSInfo bool `yaml:"tls_info"` // log the negotiated TLS details of each connection
+	TUI     bool `yaml:"tui"`      // draw a live dashboard of the echo stats instead of logging
+}
+
+// TracingConfig specifies where the spans of the Echo calls are exported.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"` // stdout or otlp, tracing is disabled if empty
+	Endpoint string `yaml:"endpoint"` // the OTLP/HTTP traces endpoint of the collector
+	Service  string `yaml:"service"`  // the service.name of the exported spans
+}
+
+// DefaultConfig returns the configuration used when no file is specified.
+func DefaultConfig() *Config {
+	return &Config{
+		Security:      SecurityMutualTLS,
+		ExpiryWarning: DefaultWarn,
+		Certs:         *DefaultCerts(),
+		Serve: ServeConfig{
+			Port:    DefaultPort,
+			Network: "tcp",
+			Faults: FaultConfig{
+				Distribution: "constant",
+				ErrorCode:    "unavailable",
+			},
+		},
+		Echo: EchoConfig{
+			Port:          DefaultPort,
+			Count:         DefaultPings,
+			Delay:         DefaultDelay,
+			Schedule:      "fixed",
+			Burst:         DefaultBurstSize,
+			MaxOutOfOrder: -1,
+		},
+		Tracing: TracingConfig{
+			Endpoint: DefaultTraceEndpoint,
+			Service:  DefaultTraceService,
+		},
+	}
+}
+
+// LoadConfig reads the YAML configuration file, any settings that are not
+// in the file keep their default values. Unknown settings are an error.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %s", err)
+	}
+
+	conf := DefaultConfig()
+	if err = yaml.UnmarshalStrict(data, conf); err != nil {
+		return nil, fmt.Errorf("could not parse config %s: %s", path, err)
+	}
+	return conf, nil
+}
+
+// Validate the configuration, returning the first error found.
+func (c *Config) Validate() error {
+	switch c.Security {
+	case SecurityMutualTLS, SecurityTLS, SecurityInsecure:
+	default:
+		return fmt.Errorf("unknown security mode %q", c.Security)
+	}
+
+	if _, err := c.Policy(); err != nil {
+		return err
+	}
+
+	if _, err := c.Faults(); err != nil {
+		return err
+	}
+
+	if _, err := c.RateLimiter(); err != nil {
+		return err
+	}
+
+	if _, err := c.Listeners(); err != nil {
+		return err
+	}
+
+	if _, err := c.Scheduler(); err != nil {
+		return err
+	}
+
+	if _, err := pb.ParseAlgorithm(c.Echo.Digest); err != nil {
+		return err
+	}
+
+	if _, err := c.Thresholds(); err != nil {
+		return err
+	}
+
+	if _, err := c.Tracer(); err != nil {
+		return err
+	}
+
+	if c.Serve.Port == 0 || c.Serve.Port > 65535 {
+		return fmt.Errorf("invalid serve port %d", c.Serve.Port)
+	}
+
+	if c.Echo.Port == 0 || c.Echo.Port > 65535 {
+		return fmt.Errorf("invalid echo port %d", c.Echo.Port)
+	}
+
+	if c.Echo.Size < 0 {
+		return errors.New("payload size cannot be negative")
+	}
+
+	for _, size := range c.Echo.SizeSweep {
+		if size < 0 {
+			return fmt.Errorf("invalid payload size %d in sweep", size)
+		}
+	}
+
+	if c.Output.TUI && len(c.Echo.SizeSweep) > 0 {
+		return errors.New("the dashboard cannot show a payload size sweep")
+	}
+
+	return nil
+}
+
+// CheckFiles returns an error if any of the certificates or keys required by
+// the security mode cannot be read.
+func (c *Config) CheckFiles() error {
+	var paths []string
+	switch c.Security {
+	case SecurityMutualTLS:
+		paths = []string{c.Certs.ServerCert, c.Certs.ServerKey, c.Certs.ClientCert, c.Certs.ClientKey, c.Certs.CA}
+	case SecurityTLS:
+		paths = []string{c.Certs.ServerCert, c.Certs.ServerKey}
+	}
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("could not find certificate file: %s", err)
+		}
+	}
+	return nil
+}
+
+// Apply sets the output settings of the package.
+func (c *Config) Apply() {
+	logmsgs = !c.Output.Quiet && !c.Output.TUI
+}
+
+// Policy creates the TLS policy, validating it.
+func (c *Config) Policy() (*TLSPolicy, error) {
+	var err error
+	policy := new(TLSPolicy)
+
+	if policy.MinVersion, err = ParseTLSVersion(c.TLS.MinVersion); err != nil {
+		return nil, err
+	}
+
+	if policy.MaxVersion, err = ParseTLSVersion(c.TLS.MaxVersion); err != nil {
+		return nil, err
+	}
+
+	if len(c.TLS.Ciphers) > 0 {
+		if policy.CipherSuites, err = ParseCipherSuites(c.TLS.Ciphers); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.TLS.Curves) > 0 {
+		if policy.Curves, err = ParseCurves(c.TLS.Curves); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tls policy: %s", err)
+	}
+
+	return policy, nil
+}
+
+// Faults creates the faults injected by the server, validating them.
+func (c *Config) Faults() (faults Faults, err error) {
+	conf := c.Serve.Faults
+	if faults.Distribution, err = ParseDistribution(conf.Distribution); err != nil {
+		return faults, err
+	}
+
+	if faults.ErrorCode, err = ParseCode(conf.ErrorCode); err != nil {
+		return faults, err
+	}
+
+	faults.Delay = conf.Delay
+	faults.Jitter = conf.Jitter
+	faults.DropRate = conf.DropRate
+	faults.ErrorRate = conf.ErrorRate
+	faults.CorruptRate = conf.CorruptRate
+
+	if err = faults.Validate(); err != nil {
+		return faults, fmt.Errorf("invalid faults: %s", err)
+	}
+	return faults, nil
+}
+
+// RateLimiter creates the rate limiter of the server, which is nil if neither
+// a default rate nor any policies are configured.
+func (c *Config) RateLimiter() (*RateLimiter, error) {
+	conf := c.Serve.RateLimit
+	if conf.Rate == 0 && len(conf.Policies) == 0 {
+		return nil, nil
+	}
+
+	policies := make([]RateLimitPolicy, 0, len(conf.Policies))
+	for _, policy := range conf.Policies {
+		policies = append(policies, RateLimitPolicy{
+			Identities: policy.Identities,
+			Limit:      RateLimit{Rate: policy.Rate, Burst: policy.Burst},
+		})
+	}
+
+	limiter, err := NewRateLimiter(RateLimit{Rate: conf.Rate, Burst: conf.Burst}, policies...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit: %s", err)
+	}
+	return limiter, nil
+}
+
+// Listeners returns the listeners of the server: either all of the configured
+// listeners or a single listener on the bind address and port, as well as
+// an insecure listener on the unix socket if one is specified.
+func (c *Config) Listeners() ([]Listener, error) {
+	var listeners []Listener
+	if len(c.Serve.Listeners) == 0 {
+		l := Listener{
+			Network:  c.Serve.Network,
+			Address:  net.JoinHostPort(c.Serve.Bind, strconv.FormatUint(uint64(c.Serve.Port), 10)),
+			Security: c.Security,
+		}
+		if err := l.Validate(); err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	for _, spec := range c.Serve.Listeners {
+		l, err := ParseListener(spec, c.Security)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	if c.Serve.Unix != "" {
+		listeners = append(listeners, Listener{"unix", c.Serve.Unix, SecurityInsecure})
+	}
+	return listeners, nil
+}
+
+// Scheduler creates the ping scheduler of the client.
+func (c *Config) Scheduler() (Scheduler, error) {
+	return NewScheduler(c.Echo.Schedule, c.Echo.Delay, c.Echo.Burst)
+}
+
+// Thresholds creates the thresholds of the stats of each target, a zero p99
+// round trip time is not checked.
+func (c *Config) Thresholds() (*Thresholds, error) {
+	var err error
+	thresholds := NoThresholds()
+	if thresholds.MaxLoss, err = ParsePercent(c.Echo.MaxLoss); err != nil {
+		return nil, err
+	}
+
+	if c.Echo.MaxP99 > 0 {
+		thresholds.MaxP99 = c.Echo.MaxP99
+	}
+
+	thresholds.MaxOutOfOrder = c.Echo.MaxOutOfOrder
+	return thresholds, nil
+}
+
+// Tracer creates the tracer of the Echo calls, which is nil if tracing is
+// not enabled. The tracer must be started to export spans periodically.
+func (c *Config) Tracer() (*Tracer, error) {
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "":
+		return nil, nil
+	case "stdout":
+		return NewTracer(c.Tracing.Service, &StdoutExporter{Writer: os.Stdout}), nil
+	case "otlp":
+		return NewTracer(c.Tracing.Service, NewOTLPExporter(c.Tracing.Endpoint)), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", c.Tracing.Exporter)
+	}
+}
+
+// MutualTLS returns true if the client connects to the address with mutual
+// TLS, so that the client certificates are used.
+func (c *Config) MutualTLS(addr string) bool {
+	return c.Security == SecurityMutualTLS && !strings.HasPrefix(addr, unixPrefix)
+}
+
+// Dailer returns the dailer of the client for the security mode. Unix socket
+// addresses are always dialed insecurely like the unix listener of the server.
+func (c *Config) Dailer(addr string) (Dailer, error) {
+	if strings.HasPrefix(addr, unixPrefix) {
+		return Insecure, nil
+	}
+
+	switch c.Security {
+	case SecurityTLS:
+		return TLSCerts(&c.Certs), nil
+	case SecurityInsecure:
+		return Insecure, nil
+	}
+
+	policy, err := c.Policy()
+	if err != nil {
+		return nil, err
+	}
+	return MutualTLSPolicy(&c.Certs, policy), nil
+}
+
+// Target returns the address of the target, adding the echo port if the
+// target does not specify one.
+func (c *Config) Target(target string) string {
+	if strings.HasPrefix(target, unixPrefix) {
+		return target
+	}
+
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(target, strconv.FormatUint(uint64(c.Echo.Port), 10))
+}
@@ -0,0 +1,114 @@
+package sping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sping.yaml")
+	data := []byte("security: tls\nserve:\n  port: 4321\necho:\n  delay: 250ms\n  targets: [localhost, \"[::1]:3265\"]\n")
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Security != SecurityTLS || conf.Serve.Port != 4321 || conf.Echo.Delay != 250*time.Millisecond {
+		t.Errorf("settings in the config file were not loaded: %+v", conf)
+	}
+
+	// Settings missing from the file keep their defaults
+	if conf.Echo.Port != DefaultPort || conf.Echo.Count != DefaultPings || conf.Certs.CA != ExampleCA {
+		t.Errorf("settings missing from the config file are not the defaults: %+v", conf)
+	}
+
+	if addr := conf.Target(conf.Echo.Targets[0]); addr != "localhost:3264" {
+		t.Errorf("expected the echo port to be added to the target, got %s", addr)
+	}
+
+	if addr := conf.Target(conf.Echo.Targets[1]); addr != "[::1]:3265" {
+		t.Errorf("expected the target port to be kept, got %s", addr)
+	}
+
+	// Unknown settings are an error
+	if err = ioutil.WriteFile(path, []byte("echo:\n  counts: 4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = LoadConfig(path); err == nil {
+		t.Error("expected an unknown setting to fail to load")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("expected the default config to be valid: %s", err)
+	}
+
+	invalid := []func(*Config){
+		func(c *Config) { c.Security = "plaintext" },
+		func(c *Config) { c.TLS.MinVersion = "1.0" },
+		func(c *Config) { c.Serve.Faults.DropRate = 2 },
+		func(c *Config) { c.Echo.Schedule = "random" },
+		func(c *Config) { c.Echo.Delay = 0 },
+		func(c *Config) { c.Echo.Delay = -time.Millisecond },
+		func(c *Config) { c.Echo.Digest = "md5" },
+		func(c *Config) { c.Echo.Port = 0 },
+		func(c *Config) { c.Echo.SizeSweep = []int{64, -1} },
+	}
+
+	for i, modify := range invalid {
+		conf := DefaultConfig()
+		modify(conf)
+		if err := conf.Validate(); err == nil {
+			t.Errorf("case %d: expected config to be invalid", i)
+		}
+	}
+}
+
+func TestConfigCerts(t *testing.T) {
+	conf := DefaultConfig()
+	if *DefaultCerts() != conf.Certs || conf.Certs.ServerCert != ServerCert {
+		t.Errorf("expected the example certificates by default got %+v", conf.Certs)
+	}
+
+	// The dialers load the certificates of the config
+	conf.Certs.ServerCert = filepath.Join("testdata", "missing.crt")
+	conf.Certs.ClientCert = filepath.Join("testdata", "missing.crt")
+
+	for _, security := range []string{SecurityTLS, SecurityMutualTLS} {
+		conf.Security = security
+		dailer, err := conf.Dailer("localhost:3264")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = dailer("localhost:3264"); err == nil || !strings.Contains(err.Error(), "missing.crt") {
+			t.Errorf("expected %s to load the configured certificates got %v", security, err)
+		}
+	}
+
+	// The server loads the certificates it is given
+	server := &PingServer{Certs: &conf.Certs}
+	if _, _, err := server.credentials(SecurityTLS); err == nil || !strings.Contains(err.Error(), "missing.crt") {
+		t.Errorf("expected the server to load the configured certificates got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package sping
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values of the live dashboard.
+const (
+	DefaultRefresh    = time.Second // how often the dashboard is redrawn
+	DefaultSparkWidth = 40          // the number of refreshes in the sparkline
+)
+
+// The bars of the sparkline from the fastest to the slowest round trip time.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline samples of refreshes without a mean round trip time.
+const (
+	sparkIdle = time.Duration(0)  // no pings were sent or pongs received
+	sparkLost = time.Duration(-1) // pings were sent but no pongs received
+)
+
+// Dashboard draws a live updating view of the stats of the clients pinging
+// each target, one row per target with a sparkline of the mean round trip
+// time of each refresh, the loss percentage and the jitter.
+type Dashboard struct {
+	sync.Mutex
+	Refresh time.Duration   // how often the dashboard is redrawn
+	Width   int             // the number of refreshes in the sparkline
+	out     io.Writer       // the terminal to draw the dashboard on
+	rows    []*dashboardRow // the rows in the order of the targets
+	started time.Time       // when the dashboard was started
+	drawn   int             // the number of lines drawn by the last refresh
+	stop    chan struct{}   // closed to stop refreshing the dashboard
+	done    chan struct{}   // closed when the final refresh is drawn
+	once    sync.Once       // ensures the dashboard is only stopped once
+}
+
+// A row of the dashboard tracks the stats at the previous refresh so that
+// each sparkline sample only includes the pongs received since then.
+type dashboardRow struct {
+	target   string
+	stats    *Stats
+	sent     uint
+	received uint
+	totalRTT time.Duration
+	samples  []time.Duration
+}
+
+// NewDashboard creates a dashboard with a row for each of the targets that
+// draws on the terminal out.
+func NewDashboard(out io.Writer, targets ...string) *Dashboard {
+	d := &Dashboard{
+		Refresh: DefaultRefresh,
+		Width:   DefaultSparkWidth,
+		out:     out,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for _, target := range targets {
+		d.rows = append(d.rows, &dashboardRow{target: target})
+	}
+	return d
+}
+
+// Watch shows the stats of the client pinging the target in its row.
+func (d *Dashboard) Watch(target string, stats *Stats) {
+	d.Lock()
+	defer d.Unlock()
+
+	for _, row := range d.rows {
+		if row.target == target {
+			row.stats = stats
+			return
+		}
+	}
+	d.rows = append(d.rows, &dashboardRow{target: target, stats: stats})
+}
+
+// Start redrawing the dashboard every refresh until it is stopped.
+func (d *Dashboard) Start() {
+	d.started = time.Now()
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.Refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.draw()
+			case <-d.stop:
+				d.draw()
+				return
+			}
+		}
+	}()
+}
+
+// Stop redrawing the dashboard, drawing it a final time with the last stats.
+func (d *Dashboard) Stop() {
+	d.once.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// Redraws the dashboard in place of the previous refresh.
+func (d *Dashboard) draw() {
+	lines := d.update()
+
+	var frame strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&frame, "\x1b[%dA", d.drawn)
+	}
+
+	for _, line := range lines {
+		frame.WriteString("\x1b[2K")
+		frame.WriteString(line)
+		frame.WriteString("\n")
+	}
+
+	d.drawn = len(lines)
+	io.WriteString(d.out, frame.String())
+}
+
+// Samples the stats of every row and returns the lines of the dashboard.
+func (d *Dashboard) update() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	lines := []string{fmt.Sprintf(
+		"sping %d targets, %s elapsed, refreshed every %s",
+		len(d.rows), time.Since(d.started).Round(time.Second), d.Refresh,
+	)}
+
+	for _, row := range d.rows {
+		row.sample(d.Width)
+		lines = append(lines, row.line(d.Width))
+	}
+	return lines
+}
+
+// Adds the mean round trip time of the pongs received since the previous
+// sample to the sparkline, keeping at most width samples.
+func (r *dashboardRow) sample(width int) {
+	if r.stats == nil {
+		return
+	}
+
+	r.stats.Lock()
+	sent, received, totalRTT := r.stats.Sent, r.stats.Received, r.stats.TotalRTT
+	r.stats.Unlock()
+
+	sample := sparkIdle
+	switch {
+	case received > r.received:
+		sample = (totalRTT - r.totalRTT) / time.Duration(received-r.received)
+	case sent > r.sent:
+		sample = sparkLost
+	}
+
+	r.sent, r.received, r.totalRTT = sent, received, totalRTT
+	r.samples = append(r.samples, sample)
+	if len(r.samples) > width {
+		r.samples = r.samples[len(r.samples)-width:]
+	}
+}
+
+// Returns the row with the sparkline padded to width and the summary stats.
+func (r *dashboardRow) line(width int) string {
+	if r.stats == nil {
+		return fmt.Sprintf("%-24s connecting", r.target)
+	}
+
+	r.stats.Lock()
+	sent, received, jitter := r.stats.Sent, r.stats.Received, r.stats.Jitter
+	var mean time.Duration
+	if received > 0 {
+		mean = r.stats.TotalRTT / time.Duration(received)
+	}
+	r.stats.Unlock()
+
+	var loss float64
+	if sent > 0 {
+		loss = 100 * float64(sent-received) / float64(sent)
+	}
+
+	return fmt.Sprintf(
+		"%-24s %-*s %6d sent %5.1f%% loss  avg %-10s jitter %s",
+		r.target, width, sparkline(r.samples), sent, loss, round(mean), round(jitter),
+	)
+}
+
+// Draws the samples as a sparkline scaled between the fastest and slowest
+// mean round trip times, lost samples are drawn as ! and idle samples blank.
+func sparkline(samples []time.Duration) string {
+	var min, max time.Duration
+	for _, sample := range samples {
+		if sample <= 0 {
+			continue
+		}
+		if min == 0 || sample < min {
+			min = sample
+		}
+		if sample > max {
+			max = sample
+		}
+	}
+
+	line := make([]rune, 0, len(samples))
+	for _, sample := range samples {
+		switch {
+		case sample == sparkIdle:
+			line = append(line, ' ')
+		case sample == sparkLost:
+			line = append(line, '!')
+		case max == min:
+			line = append(line, sparks[0])
+		default:
+			idx := int(sample-min) * (len(sparks) - 1) / int(max-min)
+			line = append(line, sparks[idx])
+		}
+	}
+	return string(line)
+}
@@ -0,0 +1,67 @@
+package sping
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSparkline(t *testing.T) {
+	samples := []time.Duration{
+		time.Millisecond, 8 * time.Millisecond, sparkIdle, sparkLost, 4500 * time.Microsecond,
+	}
+
+	if line := sparkline(samples); line != "▁█ !▄" {
+		t.Errorf("unexpected sparkline %q", line)
+	}
+
+	if line := sparkline([]time.Duration{time.Millisecond, time.Millisecond}); line != "▁▁" {
+		t.Errorf("unexpected sparkline of constant samples %q", line)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	out := new(bytes.Buffer)
+	dashboard := NewDashboard(out, "alpha:3264", "bravo:3264")
+
+	stats := new(Stats)
+	dashboard.Watch("bravo:3264", stats)
+
+	// Two pongs received then a ping lost before the next refresh
+	stats.Send()
+	stats.Update(2*time.Millisecond, nil)
+	stats.Send()
+	stats.Update(4*time.Millisecond, nil)
+	lines := dashboard.update()
+
+	stats.Send()
+	lines = dashboard.update()
+
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %d lines", len(lines))
+	}
+
+	if !strings.HasPrefix(lines[1], "alpha:3264") || !strings.HasSuffix(lines[1], "connecting") {
+		t.Errorf("unexpected row of an unwatched target %q", lines[1])
+	}
+
+	row := lines[2]
+	if !strings.HasPrefix(row, "bravo:3264               ▁!") {
+		t.Errorf("unexpected sparkline in row %q", row)
+	}
+
+	if !strings.Contains(row, "3 sent  33.3% loss  avg 3ms") {
+		t.Errorf("unexpected stats in row %q", row)
+	}
+
+	// Redraws move the cursor back up over the previous frame
+	dashboard.Refresh = time.Millisecond
+	dashboard.Start()
+	time.Sleep(5 * time.Millisecond)
+	dashboard.Stop()
+
+	if !strings.Contains(out.String(), "\x1b[3A") {
+		t.Error("dashboard was not redrawn in place")
+	}
+}
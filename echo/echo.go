@@ -1,13 +1,38 @@
 package echo
 
-import "time"
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
 
 // Now returns a time message from the current Unix timestamp
 func Now() *Time {
-	return &Time{Nanoseconds: time.Now().UnixNano()}
+	return FromTime(time.Now())
+}
+
+// FromTime returns a normalized time message from a time.Time, such that the
+// seconds are the Unix seconds and the nanoseconds are in the range [0, 1e9).
+func FromTime(t time.Time) *Time {
+	return &Time{Seconds: t.Unix(), Nanoseconds: int64(t.Nanosecond())}
 }
 
-// Parse a Unix timestamp from an echo.Time message.
+// FromTimestamp returns a time message from a protobuf Timestamp.
+func FromTimestamp(ts *tspb.Timestamp) *Time {
+	if ts == nil {
+		return nil
+	}
+	return (&Time{Seconds: ts.Seconds, Nanoseconds: int64(ts.Nanos)}).Normalize()
+}
+
+// Parse a Unix timestamp from an echo.Time message. Messages from older
+// clients that set the full UnixNano value in Nanoseconds are also handled.
 func (ts *Time) Parse() time.Time {
 	if ts != nil {
 		secs := ts.Seconds
@@ -16,3 +41,65 @@ func (ts *Time) Parse() time.Time {
 	}
 	return time.Time{}
 }
+
+// Normalize returns a copy of the time message such that the nanoseconds are
+// in the range [0, 1e9), carrying any overflow into the seconds.
+func (ts *Time) Normalize() *Time {
+	if ts == nil {
+		return nil
+	}
+	return FromTime(ts.Parse())
+}
+
+// Timestamp returns the time message as a normalized protobuf Timestamp.
+func (ts *Time) Timestamp() *tspb.Timestamp {
+	if ts == nil {
+		return nil
+	}
+
+	norm := ts.Normalize()
+	return &tspb.Timestamp{Seconds: norm.Seconds, Nanos: int32(norm.Nanoseconds)}
+}
+
+// The CRC32C table used to compute payload digests.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// NewDigest computes the digest of the payload with the specified algorithm,
+// returning nil if the algorithm is NONE.
+func NewDigest(algorithm Digest_Algorithm, payload []byte) *Digest {
+	switch algorithm {
+	case Digest_CRC32C:
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, crc32.Checksum(payload, castagnoli))
+		return &Digest{Algorithm: algorithm, Value: value}
+	case Digest_SHA256:
+		sum := sha256.Sum256(payload)
+		return &Digest{Algorithm: algorithm, Value: sum[:]}
+	default:
+		return nil
+	}
+}
+
+// ParseAlgorithm returns the digest algorithm with the specified name, which
+// is case insensitive, e.g. "crc32c" or "SHA256".
+func ParseAlgorithm(name string) (Digest_Algorithm, error) {
+	if name == "" {
+		return Digest_NONE, nil
+	}
+
+	if value, ok := Digest_Algorithm_value[strings.ToUpper(name)]; ok {
+		return Digest_Algorithm(value), nil
+	}
+	return Digest_NONE, fmt.Errorf("unknown digest algorithm %q", name)
+}
+
+// Verify returns true if the digest matches the payload. A nil digest or one
+// with no algorithm always matches since there is nothing to verify.
+func (d *Digest) Verify(payload []byte) bool {
+	if d == nil || d.Algorithm == Digest_NONE {
+		return true
+	}
+
+	expected := NewDigest(d.Algorithm, payload)
+	return expected != nil && bytes.Equal(expected.Value, d.Value)
+}
@@ -6,12 +6,17 @@
 Package echo is a generated protocol buffer package.
 
 It is generated from these files:
+
 	echo.proto
 
 It has these top-level messages:
+
 	Time
+	Digest
 	Ping
 	Pong
+	Empty
+	Faults
 */
 package echo
 
@@ -35,6 +40,57 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type Digest_Algorithm int32
+
+const (
+	Digest_NONE   Digest_Algorithm = 0
+	Digest_CRC32C Digest_Algorithm = 1
+	Digest_SHA256 Digest_Algorithm = 2
+)
+
+var Digest_Algorithm_name = map[int32]string{
+	0: "NONE",
+	1: "CRC32C",
+	2: "SHA256",
+}
+var Digest_Algorithm_value = map[string]int32{
+	"NONE":   0,
+	"CRC32C": 1,
+	"SHA256": 2,
+}
+
+func (x Digest_Algorithm) String() string {
+	return proto.EnumName(Digest_Algorithm_name, int32(x))
+}
+func (Digest_Algorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1, 0} }
+
+type Faults_Distribution int32
+
+const (
+	Faults_CONSTANT    Faults_Distribution = 0
+	Faults_UNIFORM     Faults_Distribution = 1
+	Faults_NORMAL      Faults_Distribution = 2
+	Faults_EXPONENTIAL Faults_Distribution = 3
+)
+
+var Faults_Distribution_name = map[int32]string{
+	0: "CONSTANT",
+	1: "UNIFORM",
+	2: "NORMAL",
+	3: "EXPONENTIAL",
+}
+var Faults_Distribution_value = map[string]int32{
+	"CONSTANT":    0,
+	"UNIFORM":     1,
+	"NORMAL":      2,
+	"EXPONENTIAL": 3,
+}
+
+func (x Faults_Distribution) String() string {
+	return proto.EnumName(Faults_Distribution_name, int32(x))
+}
+func (Faults_Distribution) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
 type Time struct {
 	Seconds     int64 `protobuf:"varint,1,opt,name=seconds" json:"seconds,omitempty"`
 	Nanoseconds int64 `protobuf:"varint,2,opt,name=nanoseconds" json:"nanoseconds,omitempty"`
@@ -59,17 +115,43 @@ func (m *Time) GetNanoseconds() int64 {
 	return 0
 }
 
+type Digest struct {
+	Algorithm Digest_Algorithm `protobuf:"varint,1,opt,name=algorithm,enum=echo.Digest_Algorithm" json:"algorithm,omitempty"`
+	Value     []byte           `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Digest) Reset()                    { *m = Digest{} }
+func (m *Digest) String() string            { return proto.CompactTextString(m) }
+func (*Digest) ProtoMessage()               {}
+func (*Digest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Digest) GetAlgorithm() Digest_Algorithm {
+	if m != nil {
+		return m.Algorithm
+	}
+	return Digest_NONE
+}
+
+func (m *Digest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 type Ping struct {
-	Sender string `protobuf:"bytes,1,opt,name=sender" json:"sender,omitempty"`
-	Sseq   int64  `protobuf:"varint,2,opt,name=sseq" json:"sseq,omitempty"`
-	Sent   *Time  `protobuf:"bytes,3,opt,name=sent" json:"sent,omitempty"`
-	Ttl    int64  `protobuf:"varint,4,opt,name=ttl" json:"ttl,omitempty"`
+	Sender  string  `protobuf:"bytes,1,opt,name=sender" json:"sender,omitempty"`
+	Sseq    int64   `protobuf:"varint,2,opt,name=sseq" json:"sseq,omitempty"`
+	Sent    *Time   `protobuf:"bytes,3,opt,name=sent" json:"sent,omitempty"`
+	Ttl     int64   `protobuf:"varint,4,opt,name=ttl" json:"ttl,omitempty"`
+	Payload []byte  `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	Digest  *Digest `protobuf:"bytes,6,opt,name=digest" json:"digest,omitempty"`
 }
 
 func (m *Ping) Reset()                    { *m = Ping{} }
 func (m *Ping) String() string            { return proto.CompactTextString(m) }
 func (*Ping) ProtoMessage()               {}
-func (*Ping) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*Ping) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *Ping) GetSender() string {
 	if m != nil {
@@ -99,17 +181,36 @@ func (m *Ping) GetTtl() int64 {
 	return 0
 }
 
+func (m *Ping) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Ping) GetDigest() *Digest {
+	if m != nil {
+		return m.Digest
+	}
+	return nil
+}
+
 type Pong struct {
-	Success bool  `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
-	Sseq    int64 `protobuf:"varint,2,opt,name=sseq" json:"sseq,omitempty"`
-	Rseq    int64 `protobuf:"varint,3,opt,name=rseq" json:"rseq,omitempty"`
-	Sent    *Time `protobuf:"bytes,4,opt,name=sent" json:"sent,omitempty"`
+	Success     bool    `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Sseq        int64   `protobuf:"varint,2,opt,name=sseq" json:"sseq,omitempty"`
+	Rseq        int64   `protobuf:"varint,3,opt,name=rseq" json:"rseq,omitempty"`
+	Sent        *Time   `protobuf:"bytes,4,opt,name=sent" json:"sent,omitempty"`
+	Received    *Time   `protobuf:"bytes,5,opt,name=received" json:"received,omitempty"`
+	Transmitted *Time   `protobuf:"bytes,6,opt,name=transmitted" json:"transmitted,omitempty"`
+	Payload     []byte  `protobuf:"bytes,7,opt,name=payload,proto3" json:"payload,omitempty"`
+	Corrupted   bool    `protobuf:"varint,8,opt,name=corrupted" json:"corrupted,omitempty"`
+	Digest      *Digest `protobuf:"bytes,9,opt,name=digest" json:"digest,omitempty"`
 }
 
 func (m *Pong) Reset()                    { *m = Pong{} }
 func (m *Pong) String() string            { return proto.CompactTextString(m) }
 func (*Pong) ProtoMessage()               {}
-func (*Pong) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*Pong) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 func (m *Pong) GetSuccess() bool {
 	if m != nil {
@@ -139,10 +240,122 @@ func (m *Pong) GetSent() *Time {
 	return nil
 }
 
+func (m *Pong) GetReceived() *Time {
+	if m != nil {
+		return m.Received
+	}
+	return nil
+}
+
+func (m *Pong) GetTransmitted() *Time {
+	if m != nil {
+		return m.Transmitted
+	}
+	return nil
+}
+
+func (m *Pong) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Pong) GetCorrupted() bool {
+	if m != nil {
+		return m.Corrupted
+	}
+	return false
+}
+
+func (m *Pong) GetDigest() *Digest {
+	if m != nil {
+		return m.Digest
+	}
+	return nil
+}
+
+type Empty struct {
+}
+
+func (m *Empty) Reset()                    { *m = Empty{} }
+func (m *Empty) String() string            { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()               {}
+func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type Faults struct {
+	Distribution Faults_Distribution `protobuf:"varint,1,opt,name=distribution,enum=echo.Faults_Distribution" json:"distribution,omitempty"`
+	Delay        int64               `protobuf:"varint,2,opt,name=delay" json:"delay,omitempty"`
+	Jitter       int64               `protobuf:"varint,3,opt,name=jitter" json:"jitter,omitempty"`
+	DropRate     float64             `protobuf:"fixed64,4,opt,name=drop_rate,json=dropRate" json:"drop_rate,omitempty"`
+	ErrorRate    float64             `protobuf:"fixed64,5,opt,name=error_rate,json=errorRate" json:"error_rate,omitempty"`
+	ErrorCode    uint32              `protobuf:"varint,6,opt,name=error_code,json=errorCode" json:"error_code,omitempty"`
+	CorruptRate  float64             `protobuf:"fixed64,7,opt,name=corrupt_rate,json=corruptRate" json:"corrupt_rate,omitempty"`
+}
+
+func (m *Faults) Reset()                    { *m = Faults{} }
+func (m *Faults) String() string            { return proto.CompactTextString(m) }
+func (*Faults) ProtoMessage()               {}
+func (*Faults) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *Faults) GetDistribution() Faults_Distribution {
+	if m != nil {
+		return m.Distribution
+	}
+	return Faults_CONSTANT
+}
+
+func (m *Faults) GetDelay() int64 {
+	if m != nil {
+		return m.Delay
+	}
+	return 0
+}
+
+func (m *Faults) GetJitter() int64 {
+	if m != nil {
+		return m.Jitter
+	}
+	return 0
+}
+
+func (m *Faults) GetDropRate() float64 {
+	if m != nil {
+		return m.DropRate
+	}
+	return 0
+}
+
+func (m *Faults) GetErrorRate() float64 {
+	if m != nil {
+		return m.ErrorRate
+	}
+	return 0
+}
+
+func (m *Faults) GetErrorCode() uint32 {
+	if m != nil {
+		return m.ErrorCode
+	}
+	return 0
+}
+
+func (m *Faults) GetCorruptRate() float64 {
+	if m != nil {
+		return m.CorruptRate
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Time)(nil), "echo.Time")
+	proto.RegisterType((*Digest)(nil), "echo.Digest")
 	proto.RegisterType((*Ping)(nil), "echo.Ping")
 	proto.RegisterType((*Pong)(nil), "echo.Pong")
+	proto.RegisterType((*Empty)(nil), "echo.Empty")
+	proto.RegisterType((*Faults)(nil), "echo.Faults")
+	proto.RegisterEnum("echo.Digest_Algorithm", Digest_Algorithm_name, Digest_Algorithm_value)
+	proto.RegisterEnum("echo.Faults_Distribution", Faults_Distribution_name, Faults_Distribution_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -217,22 +430,143 @@ var _SecurePing_serviceDesc = grpc.ServiceDesc{
 	Metadata: "echo.proto",
 }
 
+// Client API for FaultInjection service
+
+type FaultInjectionClient interface {
+	GetFaults(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Faults, error)
+	SetFaults(ctx context.Context, in *Faults, opts ...grpc.CallOption) (*Faults, error)
+}
+
+type faultInjectionClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewFaultInjectionClient(cc *grpc.ClientConn) FaultInjectionClient {
+	return &faultInjectionClient{cc}
+}
+
+func (c *faultInjectionClient) GetFaults(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Faults, error) {
+	out := new(Faults)
+	err := grpc.Invoke(ctx, "/echo.FaultInjection/GetFaults", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *faultInjectionClient) SetFaults(ctx context.Context, in *Faults, opts ...grpc.CallOption) (*Faults, error) {
+	out := new(Faults)
+	err := grpc.Invoke(ctx, "/echo.FaultInjection/SetFaults", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for FaultInjection service
+
+type FaultInjectionServer interface {
+	GetFaults(context.Context, *Empty) (*Faults, error)
+	SetFaults(context.Context, *Faults) (*Faults, error)
+}
+
+func RegisterFaultInjectionServer(s *grpc.Server, srv FaultInjectionServer) {
+	s.RegisterService(&_FaultInjection_serviceDesc, srv)
+}
+
+func _FaultInjection_GetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaultInjectionServer).GetFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/echo.FaultInjection/GetFaults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaultInjectionServer).GetFaults(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FaultInjection_SetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Faults)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaultInjectionServer).SetFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/echo.FaultInjection/SetFaults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaultInjectionServer).SetFaults(ctx, req.(*Faults))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _FaultInjection_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "echo.FaultInjection",
+	HandlerType: (*FaultInjectionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetFaults",
+			Handler:    _FaultInjection_GetFaults_Handler,
+		},
+		{
+			MethodName: "SetFaults",
+			Handler:    _FaultInjection_SetFaults_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "echo.proto",
+}
+
 func init() { proto.RegisterFile("echo.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 218 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0xb1, 0x4e, 0xc3, 0x40,
-	0x10, 0x44, 0x31, 0x5e, 0x05, 0x98, 0x34, 0x68, 0x0b, 0x64, 0x51, 0x20, 0xcb, 0x55, 0x2a, 0x17,
-	0xe1, 0x0f, 0x90, 0xe8, 0x23, 0xc3, 0x0f, 0xc0, 0x79, 0x65, 0x47, 0x82, 0x5d, 0xb8, 0x73, 0xfe,
-	0x3f, 0xba, 0x8d, 0x2d, 0xa5, 0x70, 0x37, 0xf3, 0xf6, 0xf6, 0x66, 0xee, 0x00, 0x09, 0xa3, 0xb5,
-	0x7f, 0xd1, 0x26, 0x63, 0xca, 0xba, 0x79, 0x03, 0x7d, 0x1e, 0x7f, 0x85, 0x2b, 0xdc, 0x25, 0x09,
-	0xa6, 0x7d, 0xaa, 0x8a, 0xba, 0xd8, 0x95, 0xdd, 0x62, 0xb9, 0xc6, 0x56, 0xbf, 0xd4, 0x96, 0xe9,
-	0xad, 0x4f, 0xaf, 0x51, 0xd3, 0x83, 0x0e, 0x47, 0x1d, 0xf8, 0x09, 0x9b, 0x24, 0xda, 0x4b, 0xf4,
-	0x2b, 0x1e, 0xba, 0xd9, 0x31, 0x83, 0x52, 0x92, 0xff, 0x79, 0xd5, 0x35, 0xbf, 0x80, 0x92, 0xe8,
-	0x54, 0x95, 0x75, 0xb1, 0xdb, 0xee, 0xd1, 0x7a, 0xb1, 0xdc, 0xa4, 0x73, 0xce, 0x8f, 0x28, 0xa7,
-	0xe9, 0xa7, 0x22, 0x5f, 0xc9, 0xb2, 0x19, 0x41, 0x07, 0xd3, 0xc1, 0x9b, 0x9e, 0x42, 0x90, 0x74,
-	0x69, 0x7a, 0xdf, 0x2d, 0x76, 0x35, 0x87, 0x41, 0x31, 0xb3, 0xf2, 0xc2, 0xe2, 0x75, 0x36, 0xad,
-	0x67, 0xef, 0x5b, 0xe0, 0x43, 0xc2, 0x29, 0x8a, 0xbf, 0xaa, 0x06, 0xbd, 0x87, 0xd1, 0x78, 0x3e,
-	0x97, 0xd9, 0xf3, 0xa2, 0x4d, 0x87, 0xe6, 0xe6, 0x7b, 0xe3, 0x1f, 0xfa, 0x7a, 0x0e, 0x00, 0x00,
-	0xff, 0xff, 0x4b, 0xd1, 0x21, 0x44, 0x5e, 0x01, 0x00, 0x00,
+	// 602 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0x4f, 0x6f, 0xd3, 0x4e,
+	0x10, 0x8d, 0x13, 0xc7, 0x89, 0xc7, 0xfe, 0xf5, 0x67, 0x8d, 0x50, 0x65, 0xca, 0x1f, 0x05, 0x0b,
+	0xa1, 0x22, 0x41, 0x0e, 0x29, 0x70, 0xe3, 0x10, 0xd2, 0x14, 0x2a, 0xb5, 0x4e, 0xb5, 0x09, 0x12,
+	0x37, 0xe4, 0xda, 0xa3, 0xd4, 0x55, 0xe2, 0x0d, 0xeb, 0x4d, 0xa5, 0x9e, 0xf9, 0x1a, 0x1c, 0xf9,
+	0x16, 0x7c, 0x39, 0xb4, 0xbb, 0x4e, 0xea, 0xf0, 0xef, 0x36, 0xf3, 0xde, 0x9b, 0xf1, 0xcc, 0xdb,
+	0x49, 0x00, 0x28, 0xbd, 0xe2, 0xfd, 0x95, 0xe0, 0x92, 0xa3, 0xad, 0xe2, 0xe8, 0x1d, 0xd8, 0xb3,
+	0x7c, 0x49, 0x18, 0x42, 0xa7, 0xa4, 0x94, 0x17, 0x59, 0x19, 0x5a, 0x3d, 0xeb, 0xb0, 0xc5, 0x36,
+	0x29, 0xf6, 0xc0, 0x2b, 0x92, 0x82, 0x6f, 0xd8, 0xa6, 0x66, 0xeb, 0x50, 0xf4, 0xd5, 0x02, 0xe7,
+	0x38, 0x9f, 0x53, 0x29, 0xf1, 0x15, 0xb8, 0xc9, 0x62, 0xce, 0x45, 0x2e, 0xaf, 0x96, 0xba, 0xd1,
+	0xde, 0x60, 0xbf, 0xaf, 0x3f, 0x6a, 0x04, 0xfd, 0xe1, 0x86, 0x65, 0x77, 0x42, 0xbc, 0x07, 0xed,
+	0x9b, 0x64, 0xb1, 0x26, 0xdd, 0xdc, 0x67, 0x26, 0x89, 0x5e, 0x82, 0xbb, 0x55, 0x63, 0x17, 0xec,
+	0x78, 0x12, 0x8f, 0x83, 0x06, 0x02, 0x38, 0x23, 0x36, 0x3a, 0x1a, 0x8c, 0x02, 0x4b, 0xc5, 0xd3,
+	0x0f, 0xc3, 0xc1, 0xeb, 0x37, 0x41, 0x33, 0xfa, 0x6e, 0x81, 0x7d, 0x91, 0x17, 0x73, 0xdc, 0x07,
+	0xa7, 0xa4, 0x22, 0x23, 0xa1, 0x07, 0x70, 0x59, 0x95, 0x21, 0x82, 0x5d, 0x96, 0xf4, 0xa5, 0xda,
+	0x40, 0xc7, 0xf8, 0x18, 0xec, 0x92, 0x0a, 0x19, 0xb6, 0x7a, 0xd6, 0xa1, 0x37, 0x00, 0x33, 0xaa,
+	0x32, 0x84, 0x69, 0x1c, 0x03, 0x68, 0x49, 0xb9, 0x08, 0x6d, 0x5d, 0xa2, 0x42, 0x65, 0xd4, 0x2a,
+	0xb9, 0x5d, 0xf0, 0x24, 0x0b, 0xdb, 0x7a, 0xda, 0x4d, 0x8a, 0x4f, 0xc1, 0xc9, 0xf4, 0x92, 0xa1,
+	0xa3, 0xbb, 0xf9, 0xf5, 0xc5, 0x59, 0xc5, 0x45, 0xdf, 0x9a, 0x60, 0x5f, 0xf0, 0x62, 0xae, 0x1d,
+	0x5f, 0xa7, 0x29, 0x95, 0xc6, 0xf1, 0x2e, 0xdb, 0xa4, 0x7f, 0x1c, 0x14, 0xc1, 0x16, 0x0a, 0x6b,
+	0x19, 0x4c, 0xd4, 0x87, 0xb7, 0xff, 0x32, 0xfc, 0x33, 0xe8, 0x0a, 0x4a, 0x29, 0xbf, 0x21, 0x33,
+	0xeb, 0xae, 0x66, 0xcb, 0xe1, 0x0b, 0xf0, 0xa4, 0x48, 0x8a, 0x72, 0x99, 0x4b, 0x49, 0x59, 0xe8,
+	0xfc, 0x26, 0xad, 0xd3, 0x75, 0x03, 0x3a, 0xbb, 0x06, 0x3c, 0x04, 0x37, 0xe5, 0x42, 0xac, 0x57,
+	0xaa, 0x4b, 0x57, 0xef, 0x74, 0x07, 0xd4, 0xec, 0x71, 0xff, 0x61, 0x4f, 0x07, 0xda, 0xe3, 0xe5,
+	0x4a, 0xde, 0x46, 0x3f, 0x9a, 0xe0, 0x9c, 0x24, 0xeb, 0x85, 0x2c, 0xf1, 0x2d, 0xf8, 0x59, 0x5e,
+	0x4a, 0x91, 0x5f, 0xae, 0x65, 0xce, 0x8b, 0xea, 0xae, 0xee, 0x9b, 0x7a, 0xa3, 0xe9, 0x1f, 0xd7,
+	0x04, 0x6c, 0x47, 0xae, 0xae, 0x2b, 0xa3, 0x45, 0x72, 0x5b, 0xf9, 0x69, 0x12, 0x75, 0x25, 0xd7,
+	0x6a, 0x21, 0x51, 0x59, 0x5a, 0x65, 0xf8, 0x00, 0xdc, 0x4c, 0xf0, 0xd5, 0x67, 0x91, 0x48, 0xd2,
+	0xce, 0x5a, 0xac, 0xab, 0x00, 0x96, 0x48, 0xc2, 0x47, 0x00, 0x24, 0x04, 0x17, 0x86, 0x6d, 0x6b,
+	0xd6, 0xd5, 0xc8, 0x2e, 0x9d, 0xf2, 0x8c, 0xb4, 0x8f, 0xff, 0x55, 0xf4, 0x88, 0x67, 0x84, 0x4f,
+	0xc0, 0xaf, 0xec, 0x30, 0xf5, 0x1d, 0x5d, 0xef, 0x55, 0x98, 0xea, 0x10, 0x9d, 0x80, 0x5f, 0xdf,
+	0x04, 0x7d, 0xe8, 0x8e, 0x26, 0xf1, 0x74, 0x36, 0x8c, 0x67, 0x41, 0x03, 0x3d, 0xe8, 0x7c, 0x8c,
+	0x4f, 0x4f, 0x26, 0xec, 0xdc, 0xdc, 0x7e, 0x3c, 0x61, 0xe7, 0xc3, 0xb3, 0xa0, 0x89, 0xff, 0x83,
+	0x37, 0xfe, 0x74, 0x31, 0x89, 0xc7, 0xf1, 0xec, 0x74, 0x78, 0x16, 0xb4, 0x06, 0x7d, 0x80, 0x29,
+	0xa5, 0x6b, 0x41, 0xfa, 0x17, 0xd1, 0x03, 0x7b, 0x9c, 0x5e, 0x71, 0xac, 0xde, 0x54, 0x61, 0x07,
+	0x9b, 0x98, 0x17, 0xf3, 0xa8, 0x31, 0x20, 0xd8, 0xd3, 0x46, 0x9e, 0x16, 0xd7, 0x94, 0xea, 0x2f,
+	0x1f, 0x82, 0xfb, 0x9e, 0x64, 0xf5, 0x02, 0x9e, 0x11, 0xeb, 0x97, 0x39, 0xf0, 0xeb, 0xc6, 0x47,
+	0x0d, 0x7c, 0x0e, 0xee, 0x74, 0xab, 0xdc, 0x21, 0x7f, 0x95, 0x5e, 0x3a, 0xfa, 0xaf, 0xe7, 0xe8,
+	0xe7, 0x00, 0x89, 0x38, 0xe5, 0x89, 0x88, 0x04, 0x00, 0x00,
 }
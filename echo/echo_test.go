@@ -0,0 +1,69 @@
+package echo
+
+import (
+	"testing"
+	"time"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestTimeNormalize(t *testing.T) {
+	now := time.Unix(1551812073, 123456789)
+
+	ts := FromTime(now)
+	if ts.Seconds != 1551812073 || ts.Nanoseconds != 123456789 {
+		t.Errorf("time not normalized: %v", ts)
+	}
+
+	// Older clients set the full UnixNano value in Nanoseconds
+	legacy := &Time{Nanoseconds: now.UnixNano()}
+	if norm := legacy.Normalize(); norm.Seconds != ts.Seconds || norm.Nanoseconds != ts.Nanoseconds {
+		t.Errorf("legacy time not normalized: %v", norm)
+	}
+
+	if !legacy.Parse().Equal(now) {
+		t.Errorf("legacy time parsed as %s", legacy.Parse())
+	}
+}
+
+func TestTimeTimestamp(t *testing.T) {
+	ts := &Time{Seconds: 10, Nanoseconds: 2500000000}
+
+	pbts := ts.Timestamp()
+	if pbts.Seconds != 12 || pbts.Nanos != 500000000 {
+		t.Errorf("timestamp not normalized: %v", pbts)
+	}
+
+	back := FromTimestamp(&tspb.Timestamp{Seconds: 12, Nanos: 500000000})
+	if !back.Parse().Equal(ts.Parse()) {
+		t.Errorf("timestamp round trip failed: %v", back)
+	}
+
+	if FromTimestamp(nil) != nil || (*Time)(nil).Timestamp() != nil {
+		t.Error("expected nil conversions of nil messages")
+	}
+}
+
+func TestDigest(t *testing.T) {
+	for _, algorithm := range []Digest_Algorithm{Digest_CRC32C, Digest_SHA256} {
+		payload := []byte("the quick brown fox jumps over the lazy dog")
+		digest := NewDigest(algorithm, payload)
+
+		if !digest.Verify(payload) {
+			t.Errorf("%s digest does not verify its own payload", algorithm)
+		}
+
+		payload[0] ^= 0xff
+		if digest.Verify(payload) {
+			t.Errorf("%s digest verified a corrupted payload", algorithm)
+		}
+	}
+
+	if NewDigest(Digest_NONE, nil) != nil || !(*Digest)(nil).Verify(nil) {
+		t.Error("expected missing digests to always verify")
+	}
+
+	if algorithm, err := ParseAlgorithm("sha256"); err != nil || algorithm != Digest_SHA256 {
+		t.Errorf("could not parse digest algorithm: %v", err)
+	}
+}
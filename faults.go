@@ -0,0 +1,281 @@
+package sping
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The full gRPC method name of the Echo RPC that faults are injected into.
+const echoMethod = "/echo.SecurePing/Echo"
+
+// The prefix of the full gRPC method names of the fault injection admin service.
+const faultAdminPrefix = "/echo.FaultInjection/"
+
+// DefaultMaxDrop bounds how long dropped calls are held without a response, so
+// that clients without a timeout and graceful shutdowns do not wait forever.
+const DefaultMaxDrop = 30 * time.Second
+
+// Faults describes the faults injected into Echo calls to test how clients
+// handle an unreliable server. Rates are probabilities between 0 and 1.
+type Faults struct {
+	Distribution pb.Faults_Distribution // the distribution of the added delay
+	Delay        time.Duration          // the mean delay added to each call
+	Jitter       time.Duration          // the spread of the delay around the mean
+	DropRate     float64                // the rate of calls that never respond
+	ErrorRate    float64                // the rate of calls that return ErrorCode
+	ErrorCode    codes.Code             // the status code of injected errors
+	CorruptRate  float64                // the rate of pongs with corrupted payloads
+}
+
+// FaultsFromProto converts the admin RPC message into faults.
+func FaultsFromProto(msg *pb.Faults) Faults {
+	return Faults{
+		Distribution: msg.Distribution,
+		Delay:        time.Duration(msg.Delay),
+		Jitter:       time.Duration(msg.Jitter),
+		DropRate:     msg.DropRate,
+		ErrorRate:    msg.ErrorRate,
+		ErrorCode:    codes.Code(msg.ErrorCode),
+		CorruptRate:  msg.CorruptRate,
+	}
+}
+
+// Proto converts the faults into the admin RPC message.
+func (f Faults) Proto() *pb.Faults {
+	return &pb.Faults{
+		Distribution: f.Distribution,
+		Delay:        int64(f.Delay),
+		Jitter:       int64(f.Jitter),
+		DropRate:     f.DropRate,
+		ErrorRate:    f.ErrorRate,
+		ErrorCode:    uint32(f.ErrorCode),
+		CorruptRate:  f.CorruptRate,
+	}
+}
+
+// Validate that the rates are probabilities and the durations are positive.
+func (f Faults) Validate() error {
+	for name, rate := range map[string]float64{"drop": f.DropRate, "error": f.ErrorRate, "corrupt": f.CorruptRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate %0.2f is not between 0 and 1", name, rate)
+		}
+	}
+
+	if f.Delay < 0 || f.Jitter < 0 {
+		return errors.New("fault delay and jitter cannot be negative")
+	}
+
+	if f.ErrorRate > 0 && f.ErrorCode == codes.OK {
+		return errors.New("an error code is required to inject errors")
+	}
+
+	return nil
+}
+
+// Enabled returns true if any faults are injected.
+func (f Faults) Enabled() bool {
+	return f.Delay > 0 || f.DropRate > 0 || f.ErrorRate > 0 || f.CorruptRate > 0
+}
+
+// String returns a human readable description of the faults.
+func (f Faults) String() string {
+	return fmt.Sprintf(
+		"%s delay %s±%s, %0.1f%% dropped, %0.1f%% %s errors, %0.1f%% corrupted",
+		strings.ToLower(f.Distribution.String()), f.Delay, f.Jitter,
+		f.DropRate*100, f.ErrorRate*100, f.ErrorCode, f.CorruptRate*100,
+	)
+}
+
+// ParseDistribution returns the delay distribution with the specified name.
+func ParseDistribution(name string) (pb.Faults_Distribution, error) {
+	if name == "" {
+		return pb.Faults_CONSTANT, nil
+	}
+
+	if value, ok := pb.Faults_Distribution_value[strings.ToUpper(name)]; ok {
+		return pb.Faults_Distribution(value), nil
+	}
+	return pb.Faults_CONSTANT, fmt.Errorf("unknown delay distribution %q", name)
+}
+
+// ParseCode returns the gRPC status code with the specified name, which is
+// case insensitive, e.g. "Unavailable" or "RESOURCE_EXHAUSTED".
+func ParseCode(name string) (codes.Code, error) {
+	normalized := strings.ToLower(strings.Replace(name, "_", "", -1))
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if strings.ToLower(code.String()) == normalized {
+			return code, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown status code %q", name)
+}
+
+// FaultInjector injects faults into Echo calls as a unary server interceptor
+// and implements the FaultInjection admin service so that the faults can be
+// changed while the server is running.
+type FaultInjector struct {
+	sync.Mutex
+	MaxDrop time.Duration // how long dropped calls are held before they fail
+	faults  Faults
+	random  *rand.Rand
+}
+
+// NewFaultInjector creates an injector with the specified faults.
+func NewFaultInjector(faults Faults) (*FaultInjector, error) {
+	if err := faults.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &FaultInjector{
+		MaxDrop: DefaultMaxDrop,
+		faults:  faults,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Faults returns the faults currently being injected.
+func (f *FaultInjector) Faults() Faults {
+	f.Lock()
+	defer f.Unlock()
+	return f.faults
+}
+
+// Set the faults to inject into subsequent calls.
+func (f *FaultInjector) Set(faults Faults) error {
+	if err := faults.Validate(); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.faults = faults
+	return nil
+}
+
+// GetFaults implements echo.FaultInjection
+func (f *FaultInjector) GetFaults(ctx context.Context, in *pb.Empty) (*pb.Faults, error) {
+	return f.Faults().Proto(), nil
+}
+
+// SetFaults implements echo.FaultInjection
+func (f *FaultInjector) SetFaults(ctx context.Context, in *pb.Faults) (*pb.Faults, error) {
+	faults := FaultsFromProto(in)
+	if err := f.Set(faults); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	Output("fault injection changed to %s", faults)
+	return faults.Proto(), nil
+}
+
+// Intercept is a grpc.UnaryServerInterceptor that injects faults into Echo
+// calls: delaying the call, dropping it by never responding, returning an
+// error instead of calling the handler or corrupting the pong payload.
+func (f *FaultInjector) Intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != echoMethod {
+		return handler(ctx, req)
+	}
+
+	// Sample all of the faults for this call at once under the lock
+	f.Lock()
+	faults := f.faults
+	delay := f.delay()
+	drop := f.random.Float64() < faults.DropRate
+	fail := f.random.Float64() < faults.ErrorRate
+	corrupt := f.random.Float64() < faults.CorruptRate
+	f.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	// Dropped calls are held until the client gives up or the maximum drop
+	if drop {
+		timer := time.NewTimer(f.MaxDrop)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return nil, status.Errorf(codes.DeadlineExceeded, "injected drop fault held for %s", f.MaxDrop)
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if fail {
+		return nil, status.Errorf(faults.ErrorCode, "injected %s fault", faults.ErrorCode)
+	}
+
+	rep, err := handler(ctx, req)
+	if err != nil {
+		return rep, err
+	}
+
+	// Flip a bit in the payload without updating its digest
+	if pong, ok := rep.(*pb.Pong); ok && corrupt && len(pong.Payload) > 0 {
+		payload := make([]byte, len(pong.Payload))
+		copy(payload, pong.Payload)
+		payload[f.intn(len(payload))] ^= 0x01
+		pong.Payload = payload
+	}
+
+	return rep, nil
+}
+
+// Samples the delay to add from the distribution, must hold the lock.
+func (f *FaultInjector) delay() time.Duration {
+	mean, jitter := float64(f.faults.Delay), float64(f.faults.Jitter)
+
+	var delay float64
+	switch f.faults.Distribution {
+	case pb.Faults_UNIFORM:
+		delay = mean + (f.random.Float64()*2-1)*jitter
+	case pb.Faults_NORMAL:
+		delay = mean + f.random.NormFloat64()*jitter
+	case pb.Faults_EXPONENTIAL:
+		delay = f.random.ExpFloat64() * mean
+	default:
+		delay = mean
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
+
+// Returns a random int in [0, n) with the lock held.
+func (f *FaultInjector) intn(n int) int {
+	f.Lock()
+	defer f.Unlock()
+	return f.random.Intn(n)
+}
+
+// A grpc.UnaryServerInterceptor that only allows clients that authenticated
+// with a certificate whose identity is in FaultAdmins to get or set the faults,
+// or any client with a verified certificate if FaultAdmins is empty.
+func (s *PingServer) authorizeFaultAdmin(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, faultAdminPrefix) {
+		return handler(ctx, req)
+	}
+
+	if err := authorizePeer(ctx, "fault injection", s.FaultAdmins); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
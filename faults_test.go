@@ -0,0 +1,169 @@
+package sping
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultsValidate(t *testing.T) {
+	invalid := []Faults{
+		{DropRate: 1.5},
+		{CorruptRate: -0.1},
+		{Delay: -time.Second},
+		{ErrorRate: 0.5},
+	}
+
+	for i, faults := range invalid {
+		if err := faults.Validate(); err == nil {
+			t.Errorf("expected faults %d to be invalid", i)
+		}
+	}
+
+	valid := Faults{Delay: time.Millisecond, ErrorRate: 0.5, ErrorCode: codes.Unavailable}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected faults to be valid: %s", err)
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	for name, expected := range map[string]codes.Code{
+		"unavailable":        codes.Unavailable,
+		"RESOURCE_EXHAUSTED": codes.ResourceExhausted,
+		"DeadlineExceeded":   codes.DeadlineExceeded,
+	} {
+		code, err := ParseCode(name)
+		if err != nil {
+			t.Errorf("could not parse %q: %s", name, err)
+		} else if code != expected {
+			t.Errorf("expected %q to parse as %s got %s", name, expected, code)
+		}
+	}
+
+	if _, err := ParseCode("not a code"); err == nil {
+		t.Error("expected an unknown code to fail to parse")
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	injector, err := NewFaultInjector(Faults{ErrorRate: 1, ErrorCode: codes.Unavailable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		ping := req.(*pb.Ping)
+		return &pb.Pong{Sseq: ping.Sseq, Payload: ping.Payload}, nil
+	}
+
+	// Every call returns the injected error
+	if _, err = injector.Intercept(context.Background(), &pb.Ping{}, info, handler); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected an injected unavailable error got %v", err)
+	}
+
+	// Change the faults at runtime to corrupt every payload
+	if _, err = injector.SetFaults(context.Background(), Faults{CorruptRate: 1}.Proto()); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("abcdefgh")
+	rep, err := injector.Intercept(context.Background(), &pb.Ping{Payload: payload}, info, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pong := rep.(*pb.Pong); string(pong.Payload) == string(payload) {
+		t.Error("expected the pong payload to be corrupted")
+	}
+
+	if string(payload) != "abcdefgh" {
+		t.Error("expected the ping payload not to be modified")
+	}
+
+	// Invalid faults are rejected by the admin service
+	if _, err = injector.SetFaults(context.Background(), &pb.Faults{DropRate: 2}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid faults to be rejected got %v", err)
+	}
+}
+
+func TestFaultInjectorMaxDrop(t *testing.T) {
+	injector, err := NewFaultInjector(Faults{DropRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	injector.MaxDrop = 10 * time.Millisecond
+
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Error("expected the dropped call not to be handled")
+		return nil, nil
+	}
+
+	// Dropped calls of clients without a timeout fail after the maximum drop
+	start := time.Now()
+	if _, err = injector.Intercept(context.Background(), &pb.Ping{}, info, handler); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected a deadline exceeded error got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the drop to be bounded by 10ms, held for %s", elapsed)
+	}
+
+	if !timedOut(context.Background(), err) {
+		t.Error("expected clients to count the dropped call as timed out")
+	}
+}
+
+func TestAuthorizeFaultAdmin(t *testing.T) {
+	certs, err := LoadCertificates(ClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified := &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{certs}},
+	}}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return &pb.Faults{}, nil }
+	cases := []struct {
+		peer   *peer.Peer
+		method string
+		admins []string
+		code   codes.Code
+	}{
+		{verified, "SetFaults", nil, codes.OK},
+		{verified, "SetFaults", []string{"admin", "127.0.0.1"}, codes.OK},
+		{verified, "SetFaults", []string{"admin"}, codes.PermissionDenied},
+		{verified, "GetFaults", []string{"admin"}, codes.PermissionDenied},
+		{&peer.Peer{}, "GetFaults", nil, codes.Unauthenticated},
+		{&peer.Peer{}, "SetFaults", nil, codes.Unauthenticated},
+	}
+
+	logmsgs = false
+	for i, tc := range cases {
+		server := &PingServer{FaultAdmin: true, FaultAdmins: tc.admins}
+		ctx := peer.NewContext(context.Background(), tc.peer)
+		info := &grpc.UnaryServerInfo{FullMethod: faultAdminPrefix + tc.method}
+		if _, err := server.authorizeFaultAdmin(ctx, &pb.Empty{}, info, handler); status.Code(err) != tc.code {
+			t.Errorf("case %d: expected %s got %s", i, tc.code, status.Code(err))
+		}
+	}
+
+	// Pings are not restricted to the fault admins
+	ctx := peer.NewContext(context.Background(), &peer.Peer{})
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	if _, err := (&PingServer{FaultAdmin: true}).authorizeFaultAdmin(ctx, &pb.Ping{}, info, handler); err != nil {
+		t.Errorf("expected pings to be allowed got %v", err)
+	}
+}
@@ -1,8 +1,29 @@
 module github.com/bbengfort/sping
 
+go 1.27.1
+
 require (
 	github.com/golang/protobuf v1.2.0
 	github.com/urfave/cli v1.20.0
 	golang.org/x/net v0.0.0-20190125091013-d26f9f9a57f3
+	google.golang.org/genproto v0.0.0-20180817151627-c66870c02cf8
 	google.golang.org/grpc v1.18.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	cloud.google.com/go v0.26.0 // indirect
+	github.com/client9/misspell v0.3.4 // indirect
+	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b // indirect
+	github.com/golang/mock v1.1.1 // indirect
+	github.com/kisielk/gotool v1.0.0 // indirect
+	golang.org/x/lint v0.0.0-20181026193005-c67002cb31c3 // indirect
+	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be // indirect
+	golang.org/x/sync v0.0.0-20180314180146-1d60e4601c6f // indirect
+	golang.org/x/sys v0.0.0-20180830151530-49385e6e1522 // indirect
+	golang.org/x/text v0.3.0 // indirect
+	golang.org/x/tools v0.0.0-20180828015842-6cd1fcedba52 // indirect
+	google.golang.org/appengine v1.1.0 // indirect
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
+	honnef.co/go/tools v0.0.0-20180728063816-88497007e858 // indirect
 )
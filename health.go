@@ -0,0 +1,39 @@
+package sping
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthService is the name the ping service reports its health under; the
+// empty service name reports the health of the server as a whole.
+const HealthService = "echo.SecurePing"
+
+// Creates the health service that reports the server as not serving until
+// the listener is up and the certificates are loaded.
+func newHealthServer() *health.Server {
+	srv := health.NewServer()
+	setHealth(srv, healthpb.HealthCheckResponse_NOT_SERVING)
+	return srv
+}
+
+// Sets the serving status of both the server and the ping service.
+func setHealth(srv *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	srv.SetServingStatus("", status)
+	srv.SetServingStatus(HealthService, status)
+}
+
+// CheckHealth returns the serving status of the service using the standard
+// grpc.health.v1 protocol, the empty service checks the whole server.
+func CheckHealth(ctx context.Context, conn *grpc.ClientConn, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	rep, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("health check failed: %s", err)
+	}
+	return rep.Status, nil
+}
@@ -0,0 +1,82 @@
+package sping
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthStatus(t *testing.T) {
+	srv := newHealthServer()
+
+	check := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		for _, service := range []string{"", HealthService} {
+			rep, err := srv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rep.Status != expected {
+				t.Errorf("expected service %q to be %s got %s", service, expected, rep.Status)
+			}
+		}
+	}
+
+	// Not serving until the listener is up
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	setHealth(srv, healthpb.HealthCheckResponse_SERVING)
+	check(healthpb.HealthCheckResponse_SERVING)
+
+	// Not serving during graceful shutdown
+	srv.Shutdown()
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestShutdownBeforeServing(t *testing.T) {
+	logmsgs = false
+	server := NewServer()
+	server.Shutdown()
+
+	// A shutdown requested while starting stops the server once it is ready
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeListeners(Listener{"tcp", "127.0.0.1:0", SecurityInsecure})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server not to serve after it was shutdown")
+	}
+}
+
+func TestShutdownWhileStarting(t *testing.T) {
+	logmsgs = false
+
+	// Shutdowns at any point of startup stop the server without an error
+	for i := 0; i < 50; i++ {
+		server := NewServer()
+		done := make(chan error, 1)
+		go func() {
+			done <- server.ServeListeners(Listener{"tcp", "127.0.0.1:0", SecurityInsecure})
+		}()
+
+		time.Sleep(time.Duration(i) * 20 * time.Microsecond)
+		server.Shutdown()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("shutdown after %d: %s", i, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the server to stop after it was shutdown")
+		}
+	}
+}
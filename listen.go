@@ -0,0 +1,91 @@
+package sping
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Security modes of the connections between the client and the server.
+const (
+	SecurityMutualTLS = "mtls"
+	SecurityTLS       = "tls"
+	SecurityInsecure  = "insecure"
+)
+
+// Listener describes an address that the server accepts connections on and
+// the security of those connections, so that one server can e.g. require
+// mutual TLS on a public port and accept insecure pings on a loopback port.
+type Listener struct {
+	Network  string // one of tcp, tcp4, tcp6 or unix
+	Address  string // the host:port to bind or the path of the unix socket
+	Security string // one of mtls, tls or insecure
+}
+
+// ParseListener parses a listener of the form [security+]network://address,
+// e.g. "mtls+tcp4://127.0.0.1:3265" or "unix:///run/sping.sock". A bare
+// address such as ":3264" listens on tcp. The security defaults to the
+// specified security if it is not part of the listener, except for unix
+// sockets which are always insecure.
+func ParseListener(s, security string) (Listener, error) {
+	l := Listener{Network: "tcp", Address: s, Security: security}
+	if idx := strings.Index(s, "://"); idx >= 0 {
+		l.Network, l.Address = s[:idx], s[idx+3:]
+		if parts := strings.SplitN(l.Network, "+", 2); len(parts) == 2 {
+			l.Security, l.Network = parts[0], parts[1]
+		} else if l.Network == "unix" {
+			l.Security = SecurityInsecure
+		}
+	}
+
+	return l, l.Validate()
+}
+
+// Validate the network, address and security of the listener.
+func (l Listener) Validate() error {
+	switch l.Security {
+	case SecurityMutualTLS, SecurityTLS, SecurityInsecure:
+	default:
+		return fmt.Errorf("unknown security mode %q", l.Security)
+	}
+
+	switch l.Network {
+	case "tcp", "tcp4", "tcp6":
+		if _, _, err := net.SplitHostPort(l.Address); err != nil {
+			return fmt.Errorf("invalid %s listener address: %s", l.Network, err)
+		}
+	case "unix":
+		if l.Address == "" {
+			return fmt.Errorf("no unix socket path specified")
+		}
+
+		// Clients are identified by their peer credentials instead of TLS
+		if l.Security != SecurityInsecure {
+			return fmt.Errorf("unix sockets are insecure, not %s", l.Security)
+		}
+	default:
+		return fmt.Errorf("unknown listener network %q", l.Network)
+	}
+
+	return nil
+}
+
+// String returns the listener in the form parsed by ParseListener.
+func (l Listener) String() string {
+	return fmt.Sprintf("%s+%s://%s", l.Security, l.Network, l.Address)
+}
+
+// Listen opens the listener, removing a stale unix socket if necessary.
+func (l Listener) Listen() (net.Listener, error) {
+	if l.Network == "unix" {
+		if err := removeStaleSocket(l.Address); err != nil {
+			return nil, fmt.Errorf("could not listen on %s: %s", l, err)
+		}
+	}
+
+	lis, err := net.Listen(l.Network, l.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %s", l, err)
+	}
+	return lis, nil
+}
@@ -0,0 +1,34 @@
+package sping
+
+import "testing"
+
+func TestParseListener(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected Listener
+	}{
+		{":3264", Listener{"tcp", ":3264", SecurityMutualTLS}},
+		{"tcp6://[::1]:3264", Listener{"tcp6", "[::1]:3264", SecurityMutualTLS}},
+		{"insecure+tcp4://127.0.0.1:3265", Listener{"tcp4", "127.0.0.1:3265", SecurityInsecure}},
+		{"unix:///run/sping.sock", Listener{"unix", "/run/sping.sock", SecurityInsecure}},
+		{"insecure+unix:///run/sping.sock", Listener{"unix", "/run/sping.sock", SecurityInsecure}},
+	}
+
+	for _, tc := range cases {
+		l, err := ParseListener(tc.spec, SecurityMutualTLS)
+		if err != nil {
+			t.Errorf("could not parse %q: %s", tc.spec, err)
+			continue
+		}
+
+		if l != tc.expected {
+			t.Errorf("expected %q to parse as %s got %s", tc.spec, tc.expected, l)
+		}
+	}
+
+	for _, spec := range []string{"3264", "udp://:3264", "plain+tcp://:3264", "unix://", "mtls+unix:///run/sping.sock", "tls+unix:///run/sping.sock"} {
+		if _, err := ParseListener(spec, SecurityMutualTLS); err == nil {
+			t.Errorf("expected %q to fail to parse", spec)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package sping
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Returns the credentials of the process on the other end of a unix socket.
+func peerCred(conn *net.UnixConn) (*PeerCredInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var serr error
+	if err = raw.Control(func(fd uintptr) {
+		cred, serr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+
+	if serr != nil {
+		return nil, fmt.Errorf("could not get peer credentials: %s", serr)
+	}
+	return &PeerCredInfo{PID: cred.Pid, UID: cred.Uid, GID: cred.Gid}, nil
+}
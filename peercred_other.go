@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sping
+
+import (
+	"errors"
+	"net"
+)
+
+// Peer credentials are only supported on Linux.
+func peerCred(conn *net.UnixConn) (*PeerCredInfo, error) {
+	return nil, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,170 @@
+package sping
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TLSPolicy restricts the protocol versions, cipher suites and curves that
+// may be negotiated on a connection, e.g. to pin TLS 1.3 for compliance. The
+// zero value of each field uses the Go defaults.
+type TLSPolicy struct {
+	MinVersion   uint16        // the minimum TLS version to negotiate
+	MaxVersion   uint16        // the maximum TLS version to negotiate
+	CipherSuites []uint16      // the TLS 1.2 cipher suites to allow
+	Curves       []tls.CurveID // the key exchange curves in preference order
+}
+
+// Curves that may be specified by name in a TLSPolicy.
+var namedCurves = []tls.CurveID{
+	tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521, tls.X25519MLKEM768,
+}
+
+// ParseTLSVersion converts a version string such as "1.2" or "TLS 1.3" into
+// the TLS version constant, returning zero for the empty string.
+func ParseTLSVersion(s string) (uint16, error) {
+	version := strings.ToUpper(strings.TrimSpace(s))
+	version = strings.TrimSpace(strings.TrimPrefix(version, "TLS"))
+
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown tls version %q", s)
+	}
+}
+
+// ParseCipherSuites converts the IANA names of cipher suites into their IDs,
+// including those that Go considers insecure so that Validate can reject them.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// ParseCurves converts curve names such as "X25519" or "P256" into their IDs.
+func ParseCurves(names []string) ([]tls.CurveID, error) {
+	curves := make([]tls.CurveID, 0, len(names))
+
+outer:
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		for _, curve := range namedCurves {
+			if strings.EqualFold(name, curve.String()) || strings.EqualFold(name, strings.TrimPrefix(curve.String(), "Curve")) {
+				curves = append(curves, curve)
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("unknown curve %q", name)
+	}
+
+	return curves, nil
+}
+
+// Validate the policy, rejecting combinations that are insecure or that cannot
+// be enforced: versions below TLS 1.2, cipher suites without forward secrecy
+// or known weaknesses and cipher suites combined with a TLS 1.3 minimum
+// (TLS 1.3 suites are not configurable).
+func (p *TLSPolicy) Validate() error {
+	if p.MinVersion != 0 && p.MinVersion < tls.VersionTLS12 {
+		return fmt.Errorf("minimum version %s is insecure, use TLS 1.2 or higher", tls.VersionName(p.MinVersion))
+	}
+
+	if p.MaxVersion != 0 && p.MaxVersion < tls.VersionTLS12 {
+		return fmt.Errorf("maximum version %s is insecure, use TLS 1.2 or higher", tls.VersionName(p.MaxVersion))
+	}
+
+	if p.MinVersion != 0 && p.MaxVersion != 0 && p.MinVersion > p.MaxVersion {
+		return errors.New("minimum tls version is greater than the maximum version")
+	}
+
+	if len(p.CipherSuites) > 0 {
+		if p.MinVersion == tls.VersionTLS13 {
+			return errors.New("cipher suites cannot be configured when pinning TLS 1.3")
+		}
+
+		for _, id := range p.CipherSuites {
+			if err := validateCipherSuite(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply the policy to the TLS configuration after validating it.
+func (p *TLSPolicy) Apply(conf *tls.Config) error {
+	if p == nil {
+		return nil
+	}
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	conf.MinVersion = p.MinVersion
+	conf.MaxVersion = p.MaxVersion
+	conf.CipherSuites = p.CipherSuites
+	conf.CurvePreferences = p.Curves
+	return nil
+}
+
+// Checks that a cipher suite is configurable for TLS 1.2 and secure.
+func validateCipherSuite(id uint16) error {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return fmt.Errorf("cipher suite %s is insecure", suite.Name)
+		}
+	}
+
+	for _, suite := range tls.CipherSuites() {
+		if suite.ID != id {
+			continue
+		}
+
+		tls12 := false
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				tls12 = true
+			}
+		}
+
+		if !tls12 {
+			return fmt.Errorf("cipher suite %s cannot be configured, only TLS 1.2 suites are configurable", suite.Name)
+		}
+
+		if !strings.HasPrefix(suite.Name, "TLS_ECDHE_") {
+			return fmt.Errorf("cipher suite %s does not provide forward secrecy", suite.Name)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown cipher suite 0x%04x", id)
+}
@@ -0,0 +1,58 @@
+package sping
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTLSPolicyValidate(t *testing.T) {
+	cases := []struct {
+		policy TLSPolicy
+		valid  bool
+	}{
+		{TLSPolicy{}, true},
+		{TLSPolicy{MinVersion: tls.VersionTLS13}, true},
+		{TLSPolicy{MinVersion: tls.VersionTLS11}, false},
+		{TLSPolicy{MaxVersion: tls.VersionTLS10}, false},
+		{TLSPolicy{MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS12}, false},
+		{TLSPolicy{CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}, true},
+		{TLSPolicy{CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256}}, false},
+		{TLSPolicy{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA}}, false},
+		{TLSPolicy{CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}}, false},
+		{TLSPolicy{MinVersion: tls.VersionTLS13, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}, false},
+	}
+
+	for i, tc := range cases {
+		err := tc.policy.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("case %d: expected valid policy, got %s", i, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("case %d: expected invalid policy", i)
+		}
+	}
+}
+
+func TestTLSPolicyParse(t *testing.T) {
+	if v, err := ParseTLSVersion("TLS 1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("could not parse tls version: %v", err)
+	}
+
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("expected unknown tls version error")
+	}
+
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil || len(suites) != 1 || suites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("could not parse cipher suites: %v", err)
+	}
+
+	curves, err := ParseCurves([]string{"x25519", "P256"})
+	if err != nil || len(curves) != 2 || curves[1] != tls.CurveP256 {
+		t.Errorf("could not parse curves: %v", err)
+	}
+
+	if _, err := ParseCurves([]string{"P192"}); err == nil {
+		t.Error("expected unknown curve error")
+	}
+}
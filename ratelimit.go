@@ -0,0 +1,285 @@
+package sping
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The number of pings rejected by the rate limit, published by identity so
+// that it can be collected from the /debug/vars endpoint.
+var rateLimited = expvar.NewMap("rate_limited")
+
+// The number of token buckets after which idle buckets are removed, so that
+// senders with random names cannot grow the rate limiter without bound.
+const maxRateBuckets = 10000
+
+// The number of identities whose rejected pings are counted separately, after
+// which the pings rejected for new identities are counted under otherLimited.
+const (
+	maxLimitedKeys = 1000
+	otherLimited   = "other"
+)
+
+// RateLimit is the rate that the pings of an identity are accepted at: a token
+// bucket that refills at Rate tokens per second up to Burst tokens, where each
+// ping takes a token. A zero rate is unlimited.
+type RateLimit struct {
+	Rate  float64 // the number of pings accepted per second
+	Burst int     // the number of pings accepted back to back
+}
+
+// String returns a human readable description of the rate limit.
+func (l RateLimit) String() string {
+	if l.Rate <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g pings/s (burst %d)", l.Rate, l.Burst)
+}
+
+// RateLimitPolicy applies a rate limit to specific identities instead of the
+// default rate limit, e.g. to allow a monitoring client to ping more often.
+type RateLimitPolicy struct {
+	Identities []string  // the certificate identities, unix uids or senders
+	Limit      RateLimit // the rate limit of the identities
+}
+
+// RateLimiter limits the rate of pings of each identity with a token bucket
+// per identity, so that a misbehaving client cannot hammer the server.
+type RateLimiter struct {
+	sync.Mutex
+	Default  RateLimit               // the rate limit of identities without a policy
+	Policies []RateLimitPolicy       // the first policy that matches an identity applies
+	buckets  map[string]*tokenBucket // the token bucket of each identity
+	limited  map[string]uint64       // the number of pings rejected per identity
+}
+
+// NewRateLimiter creates a rate limiter, validating the rate limits. A burst
+// of zero is the rate rounded up so that a full second of pings is accepted.
+func NewRateLimiter(limit RateLimit, policies ...RateLimitPolicy) (*RateLimiter, error) {
+	var err error
+	if limit, err = limit.validate(); err != nil {
+		return nil, err
+	}
+
+	for i := range policies {
+		if len(policies[i].Identities) == 0 {
+			return nil, errors.New("rate limit policies must specify identities")
+		}
+
+		if policies[i].Limit, err = policies[i].Limit.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RateLimiter{
+		Default:  limit,
+		Policies: policies,
+		buckets:  make(map[string]*tokenBucket),
+		limited:  make(map[string]uint64),
+	}, nil
+}
+
+// Returns the rate limit with the default burst, or an error if it is invalid.
+func (l RateLimit) validate() (RateLimit, error) {
+	if l.Rate < 0 || l.Burst < 0 {
+		return l, fmt.Errorf("invalid rate limit of %g pings per second with burst %d", l.Rate, l.Burst)
+	}
+
+	if l.Rate > 0 && l.Burst == 0 {
+		l.Burst = int(math.Ceil(l.Rate))
+	}
+	return l, nil
+}
+
+// Limit returns the rate limit that applies to the identities.
+func (r *RateLimiter) Limit(identities ...string) RateLimit {
+	for _, policy := range r.Policies {
+		for _, allowed := range policy.Identities {
+			for _, identity := range identities {
+				if identity == allowed {
+					return policy.Limit
+				}
+			}
+		}
+	}
+	return r.Default
+}
+
+// Allow takes a token from the bucket of the key, the primary identity of the
+// client, returning false and how long until the next token if it is empty.
+// The rate limit is the policy of the first matching identity.
+func (r *RateLimiter) Allow(key string, identities []string, now time.Time) (bool, time.Duration) {
+	limit := r.Limit(append([]string{key}, identities...)...)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxRateBuckets {
+			r.prune(now)
+		}
+
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		r.buckets[key] = bucket
+	}
+
+	if wait := bucket.take(limit, now); wait > 0 {
+		r.countLimited(key)
+		return false, wait
+	}
+	return true, 0
+}
+
+// Counts a rejected ping of the identity, folding new identities into the
+// count of other identities once too many have been counted, must hold the
+// lock.
+func (r *RateLimiter) countLimited(key string) {
+	if _, ok := r.limited[key]; !ok && len(r.limited) >= maxLimitedKeys {
+		key = otherLimited
+	}
+
+	r.limited[key]++
+	rateLimited.Add(key, 1)
+}
+
+// Limited returns the number of pings rejected for each identity, where the
+// identities beyond the first thousand are counted as "other".
+func (r *RateLimiter) Limited() map[string]uint64 {
+	r.Lock()
+	defer r.Unlock()
+
+	limited := make(map[string]uint64, len(r.limited))
+	for key, count := range r.limited {
+		limited[key] = count
+	}
+	return limited
+}
+
+// Removes the buckets that have refilled, which are the same as new buckets,
+// must hold the lock.
+func (r *RateLimiter) prune(now time.Time) {
+	for key, bucket := range r.buckets {
+		limit := r.Limit(key)
+		if limit.Rate <= 0 || bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// A token bucket with the number of tokens at the time of the last ping.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Refills the bucket since the last ping and takes a token, returning how long
+// until the next token is available if the bucket is empty.
+func (b *tokenBucket) take(limit RateLimit, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return 0
+}
+
+// Returns the key of the rate limit of a ping, the authenticated identity of
+// the client if it has one or its sender otherwise, and all of its identities.
+func rateIdentity(ctx context.Context, ping *pb.Ping) (string, []string) {
+	sender := senderIdentity(ctx, ping.Sender)
+	p, _ := peer.FromContext(ctx)
+	if identities := peerIdentities(p); len(identities) > 0 {
+		return identities[0], append(identities, sender)
+	}
+
+	if p != nil {
+		if creds, ok := p.AuthInfo.(*PeerCredInfo); ok {
+			return creds.String(), []string{sender}
+		}
+	}
+	return sender, nil
+}
+
+// A grpc.UnaryServerInterceptor that rejects the Echo calls of clients that
+// exceed their rate limit before the ping is handled, returning the time until
+// the client can ping again as the retry info of the error.
+func (s *PingServer) limitRate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ping, ok := req.(*pb.Ping)
+	if info.FullMethod != echoMethod || !ok {
+		return handler(ctx, req)
+	}
+
+	key, identities := rateIdentity(ctx, ping)
+	if allowed, wait := s.RateLimit.Allow(key, identities, time.Now()); !allowed {
+		return nil, rateLimitError(key, wait)
+	}
+	return handler(ctx, req)
+}
+
+// Creates the ResourceExhausted error of a rejected ping with the retry delay.
+func rateLimitError(key string, wait time.Duration) error {
+	st := status.Newf(codes.ResourceExhausted, "rate limit of %s exceeded, retry in %s", key, wait)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(wait)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// RetryDelay returns the delay of the retry info of a rate limit error, and
+// false if the error is not a ResourceExhausted error with retry info, such as
+// the errors of other limits of the server or of a proxy.
+func RetryDelay(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return 0, false
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if delay, err := ptypes.Duration(info.RetryDelay); err == nil {
+				return delay, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Records a ping rejected by the rate limit of the server as lost, returning
+// how long the client should wait before the next ping, and false if the ping
+// was not rate limited. The server never handled the rejected ping, so the
+// next ping reuses its sequence number to stay in order.
+func (c *PingClient) rateLimited(sent time.Time, err error) (time.Duration, bool) {
+	delay, limited := RetryDelay(err)
+	if !limited {
+		return 0, false
+	}
+
+	c.Stats().Throttle()
+	c.record(sent, 0, nil, nil, err)
+	c.output("ping %d rate limited, retrying in %s", c.sequence, delay)
+	c.sequence--
+	return delay, true
+}
@@ -0,0 +1,200 @@
+package sping
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimit{Rate: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The burst defaults to the rate
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("alice", nil, now); !ok {
+			t.Fatalf("expected ping %d of the burst to be allowed", i)
+		}
+	}
+
+	ok, wait := limiter.Allow("alice", nil, now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("expected the ping to be limited for 500ms got %t %s", ok, wait)
+	}
+
+	// Each identity has its own bucket
+	if ok, _ := limiter.Allow("bob", nil, now); !ok {
+		t.Error("expected the ping of another identity to be allowed")
+	}
+
+	// The bucket refills at the rate
+	if ok, _ := limiter.Allow("alice", nil, now.Add(500*time.Millisecond)); !ok {
+		t.Error("expected the ping to be allowed after the bucket refilled")
+	}
+
+	if limited := limiter.Limited(); limited["alice"] != 1 || limited["bob"] != 0 {
+		t.Errorf("unexpected rate limited counts %v", limited)
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	if _, err := NewRateLimiter(RateLimit{Rate: -1}); err == nil {
+		t.Error("expected a negative rate to be invalid")
+	}
+
+	if _, err := NewRateLimiter(RateLimit{}, RateLimitPolicy{Limit: RateLimit{Rate: 1}}); err == nil {
+		t.Error("expected a policy without identities to be invalid")
+	}
+
+	limiter, err := NewRateLimiter(
+		RateLimit{Rate: 1},
+		RateLimitPolicy{Identities: []string{"monitor"}, Limit: RateLimit{Rate: 100, Burst: 10}},
+		RateLimitPolicy{Identities: []string{"uid:0"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limit := limiter.Limit("client", "monitor"); limit.Rate != 100 || limit.Burst != 10 {
+		t.Errorf("expected the policy of any identity to apply got %s", limit)
+	}
+
+	if limit := limiter.Limit("client"); limit.Rate != 1 || limit.Burst != 1 {
+		t.Errorf("expected the default rate limit got %s", limit)
+	}
+
+	// Identities with an unlimited policy are always allowed
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.Allow("uid:0", nil, now); !ok {
+			t.Fatal("expected an unlimited identity to be allowed")
+		}
+	}
+}
+
+func TestLimitRate(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimit{Rate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &PingServer{RateLimit: limiter, senders: make(map[string]*SenderState)}
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.Pong{Sseq: req.(*pb.Ping).Sseq}, nil
+	}
+
+	// Senders over unix sockets are limited by their authenticated uid
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: &PeerCredInfo{UID: 1000}})
+	if _, err = server.limitRate(ctx, &pb.Ping{Sender: "alice"}, info, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = server.limitRate(ctx, &pb.Ping{Sender: "bob"}, info, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a resource exhausted error got %v", err)
+	}
+
+	if delay, ok := RetryDelay(err); !ok || delay <= 0 || delay > time.Second {
+		t.Errorf("expected the error to include the retry delay got %s", delay)
+	}
+
+	// Unauthenticated senders are limited by their name
+	if _, err = server.limitRate(context.Background(), &pb.Ping{Sender: "alice"}, info, handler); err != nil {
+		t.Errorf("expected the sender to have its own bucket got %v", err)
+	}
+
+	if _, ok := RetryDelay(status.Error(codes.Unavailable, "unavailable")); ok {
+		t.Error("expected other errors not to be rate limit errors")
+	}
+
+	// Only errors with the retry info are rate limit errors
+	if _, ok := RetryDelay(status.Error(codes.ResourceExhausted, "too many streams")); ok {
+		t.Error("expected resource exhausted errors without retry info not to be rate limit errors")
+	}
+}
+
+func TestRateLimitSequence(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimit{Rate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logmsgs = false
+	server := &PingServer{RateLimit: limiter, senders: make(map[string]*SenderState)}
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return server.Echo(ctx, req.(*pb.Ping))
+	}
+
+	client := &PingClient{Name: "alice"}
+	for i := 1; i <= 6; i++ {
+		// Lift the rate limit once the second ping has been rejected
+		if i == 3 {
+			limiter.Default = RateLimit{}
+		}
+
+		rep, err := server.limitRate(context.Background(), client.Next(), info, handler)
+		if i == 2 {
+			if _, ok := client.rateLimited(time.Now(), err); !ok {
+				t.Fatalf("expected ping %d to be rate limited got %v", i, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The client reuses the sequence number of the rejected ping
+		if pong := rep.(*pb.Pong); !pong.Success || pong.Sseq != pong.Rseq {
+			t.Errorf("expected ping %d to be in order got %v", i, pong)
+		}
+	}
+
+	if stats := client.Stats(); stats.Throttled != 1 {
+		t.Errorf("expected one rate limited ping got %d", stats.Throttled)
+	}
+
+	// Rejected pings do not change the state of the server
+	server.Lock()
+	defer server.Unlock()
+	if state := server.senders["alice"]; state.Received != 5 || state.OutOfOrder != 0 || state.Sequence != 5 {
+		t.Errorf("expected 5 pings in order got %+v", state)
+	}
+}
+
+func TestRateLimitedCounts(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimit{Rate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Identities beyond the maximum are counted together
+	limiter.Lock()
+	for i := 0; i < maxLimitedKeys+10; i++ {
+		limiter.countLimited(fmt.Sprintf("sender-%d", i))
+	}
+	limiter.countLimited("sender-0")
+	limiter.Unlock()
+
+	limited := limiter.Limited()
+	if len(limited) != maxLimitedKeys+1 {
+		t.Errorf("expected %d counted identities got %d", maxLimitedKeys+1, len(limited))
+	}
+
+	if limited["sender-0"] != 2 || limited[otherLimited] != 10 {
+		t.Errorf("expected 2 rejected pings for sender-0 and 10 for others got %d and %d", limited["sender-0"], limited[otherLimited])
+	}
+}
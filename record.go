@@ -0,0 +1,162 @@
+package sping
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+)
+
+// Result classes of a recorded ping.
+const (
+	ResultOK         = "ok"           // the pong was received in sequence
+	ResultOutOfOrder = "out_of_order" // the server received the ping out of sequence
+	ResultCorrupted  = "corrupted"    // the payload was corrupted in either direction
+	ResultTimeout    = "timeout"      // no pong was received before the timeout
+	ResultError      = "error"        // the echo RPC failed
+	ResultLimited    = "rate_limited" // the server rejected the ping because of its rate limit
+)
+
+// Record is a single ping and its outcome, written as a line of JSON so that
+// a session can be analyzed after it has finished.
+type Record struct {
+	Target string        `json:"target,omitempty"` // the address of the server
+	Sender string        `json:"sender"`           // the name of the client
+	Seq    int64         `json:"seq"`              // the sequence number of the ping
+	Rseq   int64         `json:"rseq,omitempty"`   // the sequence number the server received
+	Size   int           `json:"size,omitempty"`   // the number of payload bytes
+	Sent   time.Time     `json:"sent"`             // when the ping was sent
+	RTT    time.Duration `json:"rtt,omitempty"`    // the round trip time in nanoseconds
+	Offset time.Duration `json:"offset,omitempty"` // the estimated server clock offset
+	Result string        `json:"result"`           // the result class of the ping
+	Error  string        `json:"error,omitempty"`  // the error of failed pings
+}
+
+// Received returns true if a pong was received for the ping.
+func (r *Record) Received() bool {
+	return r.Result != ResultTimeout && r.Result != ResultError && r.Result != ResultLimited
+}
+
+// Recorder writes ping records to a file as JSON lines. It is safe to share
+// between the clients pinging multiple targets.
+type Recorder struct {
+	sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+// NewRecorder creates the session file, truncating it if it exists.
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create session file: %s", err)
+	}
+
+	buf := bufio.NewWriter(file)
+	return &Recorder{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Record writes the record to the session file.
+func (r *Recorder) Record(record *Record) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if err := r.enc.Encode(record); err != nil {
+		return fmt.Errorf("could not record ping: %s", err)
+	}
+	return nil
+}
+
+// Close flushes the records and closes the session file.
+func (r *Recorder) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if err := r.buf.Flush(); err != nil {
+		r.file.Close()
+		return fmt.Errorf("could not write session file: %s", err)
+	}
+	return r.file.Close()
+}
+
+// ReadRecords reads the JSON line records of a session.
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	dec := json.NewDecoder(r)
+	for {
+		record := new(Record)
+		if err := dec.Decode(record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("could not parse record %d: %s", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// LoadRecords reads the records of the session file.
+func LoadRecords(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open session file: %s", err)
+	}
+	defer file.Close()
+	return ReadRecords(file)
+}
+
+// Returns the result class of a received pong.
+func (c *PingClient) result(pong *pb.Pong) string {
+	if err := c.Verify(pong); err != nil {
+		return ResultCorrupted
+	}
+	if !pong.Success {
+		return ResultOutOfOrder
+	}
+	return ResultOK
+}
+
+// Records the outcome of the last ping sent if the client is recording.
+func (c *PingClient) record(sent time.Time, rtt time.Duration, pong *pb.Pong, exchange *Exchange, err error) {
+	if c.Recorder == nil {
+		return
+	}
+
+	record := &Record{
+		Target: c.Target,
+		Sender: c.Name,
+		Seq:    c.sequence,
+		Size:   c.Size,
+		Sent:   sent,
+	}
+
+	_, limited := RetryDelay(err)
+	switch {
+	case err == context.DeadlineExceeded:
+		record.Result = ResultTimeout
+	case limited:
+		record.Result = ResultLimited
+		record.Error = err.Error()
+	case err != nil:
+		record.Result = ResultError
+		record.Error = err.Error()
+	default:
+		record.Result = c.result(pong)
+		record.Rseq = pong.Rseq
+		record.RTT = rtt
+		if exchange != nil {
+			record.Offset = exchange.Offset()
+		}
+	}
+
+	if err := c.Recorder.Record(record); err != nil {
+		Output("%s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package sping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "session.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := []string{ResultOK, ResultOK, ResultTimeout, ResultCorrupted, ResultOK, ResultError}
+	for i, result := range results {
+		record := &Record{
+			Target: "localhost:3264",
+			Sender: "tester",
+			Seq:    int64(i + 1),
+			Sent:   start.Add(time.Duration(i) * time.Second),
+			Result: result,
+		}
+		if record.Received() {
+			record.RTT = time.Duration(i+1) * time.Millisecond
+		}
+		if err = recorder.Record(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != len(results) {
+		t.Fatalf("expected %d records, loaded %d", len(results), len(records))
+	}
+
+	stats := RecordStats(records)
+	if stats.Sent != 6 || stats.Received != 4 || stats.Lost() != 2 || stats.Corrupted != 1 {
+		t.Errorf("unexpected stats %d sent, %d received, %d corrupted", stats.Sent, stats.Received, stats.Corrupted)
+	}
+
+	if stats.MinRTT != time.Millisecond || stats.MaxRTT != 5*time.Millisecond {
+		t.Errorf("unexpected rtt min/max %s/%s", stats.MinRTT, stats.MaxRTT)
+	}
+
+	// Filter by time range and result class
+	filter := &RecordFilter{From: start.Add(time.Second), To: start.Add(5 * time.Second), Results: []string{ResultOK}}
+	if matched := FilterRecords(records, filter); len(matched) != 2 || matched[0].Seq != 2 || matched[1].Seq != 5 {
+		t.Errorf("unexpected filtered records %v", matched)
+	}
+
+	hist := Histogram(records, 4, 8)
+	if len(hist) != 4 {
+		t.Fatalf("expected 4 histogram bins, got %d", len(hist))
+	}
+
+	// The 4ms and 5ms rtts share the last bin which has the longest bar
+	if !strings.HasSuffix(hist[0], "####     1") || !strings.HasSuffix(hist[3], "######## 2") {
+		t.Errorf("unexpected histogram\n%s", strings.Join(hist, "\n"))
+	}
+
+	timeline := Timeline(records, 5, 10)
+	if len(timeline) != 5 {
+		t.Fatalf("expected 5 timeline windows, got %d", len(timeline))
+	}
+
+	if !strings.HasSuffix(timeline[2], "100.0% loss") || !strings.HasSuffix(timeline[4], "50.0% loss") {
+		t.Errorf("unexpected timeline\n%s", strings.Join(timeline, "\n"))
+	}
+	// Bars are empty rather than negative without a width
+	if hist = Histogram(records, 4, -1); len(hist) != 4 || strings.Contains(hist[3], "#") {
+		t.Errorf("unexpected histogram without a width\n%s", strings.Join(hist, "\n"))
+	}
+}
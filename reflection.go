@@ -0,0 +1,92 @@
+package sping
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The prefix of the full gRPC method names of the server reflection service.
+const reflectionPrefix = "/grpc.reflection.v1alpha.ServerReflection/"
+
+// CertIdentities returns the identities of a certificate that can be
+// authorized: its common name and all of its subject alternative names.
+func CertIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		identities = append(identities, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	return identities
+}
+
+// Returns the identities of the verified client certificate of the peer, or
+// nil if the peer did not authenticate with a client certificate.
+func peerIdentities(p *peer.Peer) []string {
+	if p == nil {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return CertIdentities(info.State.VerifiedChains[0][0])
+}
+
+// A grpc.StreamServerInterceptor that only allows clients that authenticated
+// with a certificate whose identity is in Reflectors to use the reflection
+// service. If Reflectors is empty, any client with a verified certificate is
+// allowed, but clients without one are always refused.
+func (s *PingServer) authorizeReflection(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, reflectionPrefix) {
+		return handler(srv, stream)
+	}
+
+	if err := authorizePeer(stream.Context(), "reflection", s.Reflectors); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// Returns an error unless the peer authenticated with a verified certificate
+// whose identity is allowed to use the service, or any identity if allowed is
+// empty. Peers without a verified certificate are always refused.
+func authorizePeer(ctx context.Context, service string, allowed []string) error {
+	p, _ := peer.FromContext(ctx)
+	identities := peerIdentities(p)
+	if len(identities) == 0 {
+		return status.Errorf(codes.Unauthenticated, "%s requires a verified client certificate", service)
+	}
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	for _, identity := range identities {
+		for _, name := range allowed {
+			if identity == name {
+				return nil
+			}
+		}
+	}
+
+	Output("denied %s to %s", service, strings.Join(identities, ", "))
+	return status.Errorf(codes.PermissionDenied, "client %q is not authorized to use %s", identities[0], service)
+}
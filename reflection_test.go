@@ -0,0 +1,64 @@
+package sping
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// A server stream that only carries the context of the call.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthorizeReflection(t *testing.T) {
+	certs, err := LoadCertificates(ClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client certificate is identified by its common name and IP SAN
+	if identities := CertIdentities(certs[0]); len(identities) != 2 || identities[0] != "127.0.0.1" {
+		t.Errorf("unexpected client certificate identities %v", identities)
+	}
+
+	verified := &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{certs}},
+	}}
+
+	info := &grpc.StreamServerInfo{FullMethod: reflectionPrefix + "ServerReflectionInfo"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error { return nil }
+
+	cases := []struct {
+		peer       *peer.Peer
+		reflectors []string
+		code       codes.Code
+	}{
+		{verified, nil, codes.OK},
+		{verified, []string{"admin", "127.0.0.1"}, codes.OK},
+		{verified, []string{"admin"}, codes.PermissionDenied},
+		{&peer.Peer{}, nil, codes.Unauthenticated},
+	}
+
+	logmsgs = false
+	for i, tc := range cases {
+		server := &PingServer{Reflection: true, Reflectors: tc.reflectors}
+		stream := &contextStream{ctx: peer.NewContext(context.Background(), tc.peer)}
+		if code := status.Code(server.authorizeReflection(nil, stream, info, handler)); code != tc.code {
+			t.Errorf("case %d: expected %s got %s", i, tc.code, code)
+		}
+	}
+}
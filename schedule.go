@@ -0,0 +1,129 @@
+package sping
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Default number of pings sent back to back in each burst.
+const DefaultBurstSize = 5
+
+// Scheduler determines when each ping of a run is sent. Schedules other than
+// a fixed interval avoid synchronizing with periodic network events.
+type Scheduler interface {
+	// Next returns how long after the previous ping the next ping is sent.
+	Next() time.Duration
+}
+
+// NewScheduler creates a scheduler by name: "fixed" sends a ping every
+// interval, "poisson" sends pings with exponentially distributed gaps with a
+// mean of interval and "burst" sends burst pings back to back every interval.
+// The interval must be positive so that pings do not flood the server.
+func NewScheduler(name string, interval time.Duration, burst int) (Scheduler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("the delay between pings must be positive, not %s", interval)
+	}
+
+	switch strings.ToLower(name) {
+	case "", "fixed":
+		return &FixedScheduler{Interval: interval}, nil
+	case "poisson":
+		return NewPoissonScheduler(interval), nil
+	case "burst":
+		if burst < 1 {
+			return nil, fmt.Errorf("burst size must be at least 1, not %d", burst)
+		}
+		return &BurstScheduler{Size: burst, Interval: interval}, nil
+	default:
+		return nil, fmt.Errorf("unknown schedule %q", name)
+	}
+}
+
+// FixedScheduler sends pings at a fixed interval.
+type FixedScheduler struct {
+	Interval time.Duration // the time between pings
+}
+
+// Next implements Scheduler
+func (s *FixedScheduler) Next() time.Duration {
+	return s.Interval
+}
+
+// PoissonScheduler sends pings as a Poisson process, such that the time
+// between pings is exponentially distributed with the specified mean.
+type PoissonScheduler struct {
+	Mean   time.Duration // the mean time between pings
+	random *rand.Rand
+}
+
+// NewPoissonScheduler creates a Poisson scheduler with the specified mean.
+func NewPoissonScheduler(mean time.Duration) *PoissonScheduler {
+	return &PoissonScheduler{
+		Mean:   mean,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Next implements Scheduler
+func (s *PoissonScheduler) Next() time.Duration {
+	return time.Duration(s.random.ExpFloat64() * float64(s.Mean))
+}
+
+// BurstScheduler sends bursts of pings back to back at a fixed interval.
+type BurstScheduler struct {
+	Size     int           // the number of pings in each burst
+	Interval time.Duration // the time between the start of each burst
+	sent     int           // the number of pings scheduled so far
+}
+
+// Next implements Scheduler, sending every ping at the interval if the size of
+// the bursts is less than one.
+func (s *BurstScheduler) Next() time.Duration {
+	s.sent++
+	if s.Size < 1 || (s.sent-1)%s.Size == 0 {
+		return s.Interval
+	}
+	return 0
+}
+
+// Returns the scheduler of the client, which defaults to the fixed delay.
+func (c *PingClient) schedule() Scheduler {
+	if c.Scheduler == nil {
+		c.Scheduler = &FixedScheduler{Interval: c.Delay}
+	}
+	return c.Scheduler
+}
+
+// Returns a context that is done when the client should stop sending pings:
+// when the parent context is done, when the run duration has elapsed or when
+// the returned cancel function is called after the limit of pings is sent.
+func (c *PingClient) sending(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Duration > 0 {
+		return context.WithTimeout(ctx, c.Duration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Blocks until the next ping should be sent according to the schedule,
+// returning the time of the next ping to compute the subsequent wait from,
+// or false if the context is done and no more pings should be sent.
+func wait(ctx context.Context, scheduler Scheduler, last time.Time) (time.Time, bool) {
+	if ctx.Err() != nil {
+		return last, false
+	}
+
+	next := last.Add(scheduler.Next())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return next, true
+	case <-ctx.Done():
+		return next, false
+	}
+}
@@ -0,0 +1,51 @@
+package sping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurstScheduler(t *testing.T) {
+	scheduler, err := NewScheduler("burst", time.Second, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []time.Duration{time.Second, 0, 0, time.Second, 0, 0, time.Second}
+	for i, gap := range expected {
+		if next := scheduler.Next(); next != gap {
+			t.Errorf("ping %d: expected gap of %s got %s", i, gap, next)
+		}
+	}
+
+	// Empty bursts send every ping at the interval
+	for _, name := range []string{"fixed", "poisson", "burst"} {
+		if _, err = NewScheduler(name, 0, 3); err == nil {
+			t.Errorf("expected the %s schedule without a delay to be invalid", name)
+		}
+	}
+
+	if _, err = NewScheduler("burst", time.Second, 0); err == nil {
+		t.Error("expected a burst size of 0 to be invalid")
+	}
+
+	empty := &BurstScheduler{Interval: time.Second}
+	for i := 0; i < 3; i++ {
+		if next := empty.Next(); next != time.Second {
+			t.Errorf("ping %d: expected gap of 1s got %s", i, next)
+		}
+	}
+}
+
+func TestPoissonScheduler(t *testing.T) {
+	scheduler := NewPoissonScheduler(10 * time.Millisecond)
+
+	var total time.Duration
+	for i := 0; i < 10000; i++ {
+		total += scheduler.Next()
+	}
+
+	if mean := total / 10000; mean < 9*time.Millisecond || mean > 11*time.Millisecond {
+		t.Errorf("expected mean gap of about 10ms got %s", mean)
+	}
+}
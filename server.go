@@ -8,9 +8,13 @@ import (
 	"io/ioutil"
 	"net"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"golang.org/x/net/context"
 
@@ -29,161 +33,387 @@ const (
 // sent per sender (responding with the correct sequence).
 type PingServer struct {
 	sync.Mutex
-	sequence map[string]int64 // mapping of named hosts to pings received
-	srv      *grpc.Server     // handle to the grpc server
+	Certs         *CertConfig             // the certificates to serve, the example certificates if nil
+	Policy        *TLSPolicy              // restricts the TLS parameters of mutual TLS
+	ExpiryWarning time.Duration           // warn when certificates expire within this window
+	TicketKeys    time.Duration           // rotate the session ticket keys at this interval
+	Faults        *FaultInjector          // injects faults into Echo calls if not nil
+	RateLimit     *RateLimiter            // limits the rate of Echo calls per identity if not nil
+	FaultAdmin    bool                    // register the admin service to change the faults
+	FaultAdmins   []string                // client identities allowed to change the faults, any if empty
+	Reflection    bool                    // register the reflection service for debugging
+	Reflectors    []string                // client identities allowed to use reflection, any if empty
+	Tracer        *Tracer                 // traces every Echo call if not nil
+	UnixUIDs      []uint32                // users allowed to connect over insecure unix sockets, any if empty
+	StateFile     string                  // persist the per-sender state to this file if not empty
+	Snapshots     time.Duration           // how often the state is persisted, defaults to 30s
+	senders       map[string]*SenderState // mapping of named hosts to their sequence and stats
+	servers       []*grpc.Server          // handles to the grpc server of each security mode
+	health        *health.Server          // reports the serving status to health checks
+	stopping      bool                    // shutdown was requested, possibly before serving
+	monitor       *CertMonitor            // periodically checks certificate expiration
+	rotator       *TicketKeyRotator       // periodically rotates session ticket keys
+	snapshots     *Snapshotter            // periodically persists the state of the senders
 }
 
 // Echo implements echo.SecurePing
 func (s *PingServer) Echo(ctx context.Context, ping *pb.Ping) (*pb.Pong, error) {
+	// Stamp the receive time for the client to estimate the clock offset
+	received := pb.Now()
+
+	// Verify the payload digest if the client sent one
+	corrupted := !ping.Digest.Verify(ping.Payload)
+	if corrupted {
+		Output("payload digest mismatch in ping %d from %s", ping.Sseq, ping.Sender)
+	}
+
+	// Senders on unix sockets are also identified by their uid
+	sender := senderIdentity(ctx, ping.Sender)
 
 	// Lock the server to ensure safety of sequence state
 	s.Lock()
 	defer s.Unlock()
 
 	// If the sender is not in the sequence, assign it
-	if _, ok := s.sequence[ping.Sender]; !ok {
-		s.sequence[ping.Sender] = 0
+	state, ok := s.senders[sender]
+	if !ok {
+		state = &SenderState{FirstSeen: received.Parse()}
+		s.senders[sender] = state
 	}
 
 	// If the ping sseq is one, reset the sequence counter
 	// Otherwise increment the sequence count accordingly.
 	if ping.Sseq == 1 {
-		s.sequence[ping.Sender] = 1
+		state.Sequence = 1
 	} else {
-		s.sequence[ping.Sender]++
+		state.Sequence++
 	}
 
 	// Success is true if the sequence is not out of order
-	success := ping.Sseq == s.sequence[ping.Sender]
-	rseq := s.sequence[ping.Sender]
+	success := ping.Sseq == state.Sequence
+	rseq := state.Sequence
+
+	state.Received++
+	state.LastSeen = received.Parse()
+	if !success {
+		state.OutOfOrder++
+	}
 
 	// Create the reply message
 	pong := &pb.Pong{
-		Success: success,
-		Sseq:    ping.Sseq,
-		Rseq:    rseq,
-		Sent:    ping.Sent,
+		Success:   success,
+		Sseq:      ping.Sseq,
+		Rseq:      rseq,
+		Sent:      ping.Sent,
+		Received:  received,
+		Payload:   ping.Payload,
+		Corrupted: corrupted,
 	}
 
-	// Log the ping and return
-	Output("received ping %d/%d from %s\n", ping.Sseq, rseq, ping.Sender)
+	// Digest the echoed payload so the client can verify the return path
+	if ping.Digest != nil {
+		pong.Digest = pb.NewDigest(ping.Digest.Algorithm, pong.Payload)
+	}
+
+	// Log the ping, stamp the transmit time as late as possible and return
+	Output("received ping %d/%d from %s\n", ping.Sseq, rseq, sender)
+	pong.Transmitted = pb.Now()
 	return pong, nil
 }
 
-// Serve ping requests from gRPC messages
+// Serve ping requests from gRPC messages using mutual TLS on all interfaces.
 func (s *PingServer) Serve(port uint) error {
-	// Initialize server variables
-	s.sequence = make(map[string]int64)
-	addr := fmt.Sprintf(":%d", port)
+	return s.ServeListeners(Listener{"tcp", fmt.Sprintf(":%d", port), SecurityMutualTLS})
+}
+
+// ServeListeners serves ping requests on all of the listeners at once. The
+// listeners share the sequence state of the server so a sender may switch
+// between them. Blocks until the server is shutdown or any listener fails.
+func (s *PingServer) ServeListeners(listeners ...Listener) error {
+	// Initialize server variables, reloading the state of the senders
+	s.Lock()
+	s.health = newHealthServer()
+	s.Unlock()
+
+	if err := s.loadState(); err != nil {
+		return err
+	}
+
+	if len(listeners) == 0 {
+		return errors.New("no listeners to serve on")
+	}
+
+	// Create one gRPC server per security mode
+	servers := make(map[string]*grpc.Server)
+	var certs []string
+	for _, l := range listeners {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := servers[l.Security]; ok {
+			continue
+		}
+
+		creds, paths, err := s.credentials(l.Security)
+		if err != nil {
+			return err
+		}
+
+		servers[l.Security] = s.register(grpc.NewServer(s.options(creds...)...))
+		certs = append(certs, paths...)
+	}
+
+	// Warn if the loaded certificates are close to expiring
+	if len(certs) > 0 {
+		var err error
+		if s.monitor, err = monitorCerts(s.ExpiryWarning, certs...); err != nil {
+			return err
+		}
+	}
+
+	// Open all of the listeners before serving on any of them
+	sockets := make([]net.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		lis, err := l.Listen()
+		if err != nil {
+			for _, sock := range sockets {
+				sock.Close()
+			}
+			return err
+		}
+		sockets = append(sockets, lis)
+	}
+
+	// Do not serve if the server was shutdown while it was starting
+	s.Lock()
+	if s.stopping {
+		s.Unlock()
+		for _, sock := range sockets {
+			sock.Close()
+		}
+		s.cleanup()
+		return nil
+	}
+
+	for _, srv := range servers {
+		s.servers = append(s.servers, srv)
+	}
+	s.Unlock()
+
+	// Periodically persist the state of the senders
+	if s.StateFile != "" {
+		s.snapshots = NewSnapshotter(s.Snapshots, s.saveState)
+		s.snapshots.Start()
+	}
+
+	// Serve on every listener, stopping all of them if any fails
+	errs := make(chan error, len(listeners))
+	for i, l := range listeners {
+		Output("listening on %s", l)
+		go func(srv *grpc.Server, lis net.Listener) {
+			errs <- srv.Serve(lis)
+		}(servers[l.Security], sockets[i])
+	}
+
+	setHealth(s.health, healthpb.HealthCheckResponse_SERVING)
+
+	var err error
+	defer s.cleanup()
+	for range listeners {
+		serr := <-errs
+		if serr == nil || err != nil {
+			continue
+		}
+
+		// Servers stopped by a shutdown before they started serving
+		if serr == grpc.ErrServerStopped && s.isStopping() {
+			continue
+		}
+
+		err = fmt.Errorf("grpc serve error: %s", serr)
+		for _, srv := range servers {
+			srv.Stop()
+		}
+	}
+	return err
+}
+
+// Returns true if shutdown was requested.
+func (s *PingServer) isStopping() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.stopping
+}
 
+// Returns the gRPC server options for the credentials of the security mode
+// and the paths of the certificates that the credentials loaded.
+func (s *PingServer) credentials(security string) ([]grpc.ServerOption, []string, error) {
+	certs := certsOrDefault(s.Certs)
+	switch security {
+	case SecurityMutualTLS:
+		creds, err := s.mutualTLS(certs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []grpc.ServerOption{grpc.Creds(creds)}, []string{certs.ServerCert, certs.CA}, nil
+
+	case SecurityTLS:
+		creds, err := credentials.NewServerTLSFromFile(certs.ServerCert, certs.ServerKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load TLS keys: %s", err)
+		}
+		return []grpc.ServerOption{grpc.Creds(&loggedCredentials{creds})}, []string{certs.ServerCert}, nil
+
+	case SecurityInsecure:
+		creds := &unixCredentials{uids: s.UnixUIDs}
+		return []grpc.ServerOption{grpc.Creds(creds)}, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown security mode %q", security)
+}
+
+// Creates the mutual TLS credentials that require and verify client certs.
+func (s *PingServer) mutualTLS(certs *CertConfig) (credentials.TransportCredentials, error) {
 	// Load the certificates from disk
-	certificate, err := tls.LoadX509KeyPair(ServerCert, ServerKey)
+	certificate, err := tls.LoadX509KeyPair(certs.ServerCert, certs.ServerKey)
 	if err != nil {
-		return fmt.Errorf("could not load server key pair: %s", err)
+		return nil, fmt.Errorf("could not load server key pair: %s", err)
 	}
 
 	// Create a certificate pool from the certificate authority
 	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(ExampleCA)
+	ca, err := ioutil.ReadFile(certs.CA)
 	if err != nil {
-		return fmt.Errorf("could not read ca certificate: %s", err)
+		return nil, fmt.Errorf("could not read ca certificate: %s", err)
 	}
 
 	// Append the client certificates from the CA
 	if ok := certPool.AppendCertsFromPEM(ca); !ok {
-		return errors.New("failed to append client certs")
-	}
-
-	// Open a channel on the address for listening
-	lis, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("could not listen on %s: %s", addr, err)
+		return nil, errors.New("failed to append client certs")
 	}
 
 	// Create the TLS configuration to pass to the GRPC server
-	creds := credentials.NewTLS(&tls.Config{
+	conf := &tls.Config{
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 		Certificates: []tls.Certificate{certificate},
 		ClientCAs:    certPool,
-	})
+	}
 
-	// Create a new GRPC server with the credentials
-	s.srv = grpc.NewServer(grpc.Creds(creds))
-	pb.RegisterSecurePingServer(s.srv, s)
+	// Restrict the negotiated parameters with the policy
+	if err := s.Policy.Apply(conf); err != nil {
+		return nil, fmt.Errorf("invalid tls policy: %s", err)
+	}
 
-	if err := s.srv.Serve(lis); err != nil {
-		return fmt.Errorf("grpc serve error: %s", err)
+	// Rotate the session ticket keys used to resume client sessions
+	if s.TicketKeys > 0 {
+		s.rotator = NewTicketKeyRotator(s.TicketKeys)
+		if err = s.rotator.Start(conf); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return &loggedCredentials{credentials.NewTLS(conf)}, nil
 }
 
-// Shutdown the grpc server instance
-func (s *PingServer) Shutdown() {
-	s.srv.GracefulStop()
-}
+// Returns the gRPC server options shared by all of the serve methods.
+func (s *PingServer) options(opts ...grpc.ServerOption) []grpc.ServerOption {
+	// Trace outside of the faults so that spans include injected delays
+	var interceptors []grpc.UnaryServerInterceptor
+	if s.Faults != nil && s.FaultAdmin {
+		interceptors = append(interceptors, s.authorizeFaultAdmin)
+	}
 
-// ServeMutualTLS is an alias for Serve. It is mostly here for benchmarking.
-func (s *PingServer) ServeMutualTLS(port uint) error {
-	return s.Serve(port)
-}
+	if s.Tracer != nil {
+		interceptors = append(interceptors, s.traceEcho)
+	}
 
-// ServeTLS is a helper method for server-side encryption that does not expect
-// client authentication or credentials. It is mostly here for benchmarking.
-func (s *PingServer) ServeTLS(port uint) error {
-	// Initialize server variables
-	s.sequence = make(map[string]int64)
-	addr := fmt.Sprintf(":%d", port)
+	if s.RateLimit != nil {
+		Output("rate limiting pings to %s per identity", s.RateLimit.Default)
+		interceptors = append(interceptors, s.limitRate)
+	}
 
-	// Create the TLS credentials
-	creds, err := credentials.NewServerTLSFromFile(ServerCert, ServerKey)
-	if err != nil {
-		return fmt.Errorf("could not load TLS keys: %s", err)
+	if s.Faults != nil {
+		Output("injecting faults: %s", s.Faults.Faults())
+		interceptors = append(interceptors, s.Faults.Intercept)
 	}
 
-	// Create the gRPC server with the gredentials
-	s.srv = grpc.NewServer(grpc.Creds(creds))
+	if len(interceptors) > 0 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnary(interceptors...)))
+	}
+	if s.Reflection {
+		opts = append(opts, grpc.StreamInterceptor(s.authorizeReflection))
+	}
+	return opts
+}
 
-	// Register the handler object
-	pb.RegisterSecurePingServer(s.srv, s)
+// Registers the ping service and any optional services on the gRPC server.
+func (s *PingServer) register(srv *grpc.Server) *grpc.Server {
+	pb.RegisterSecurePingServer(srv, s)
+	healthpb.RegisterHealthServer(srv, s.health)
 
-	// Create the channel to listen on
-	lis, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("could not listen on %s: %s", addr, err)
+	if s.Faults != nil && s.FaultAdmin {
+		pb.RegisterFaultInjectionServer(srv, s.Faults)
 	}
 
-	// Serve and Listen
-	if err := s.srv.Serve(lis); err != nil {
-		return fmt.Errorf("grpc serve error: %s", err)
+	if s.Reflection {
+		reflection.Register(srv)
 	}
-
-	return nil
+	return srv
 }
 
-// ServeInsecure is a helper method for no server-side encryption.
-// It is mostly here for benchmarking.
-func (s *PingServer) ServeInsecure(port uint) error {
-	// Initialize server variables
-	s.sequence = make(map[string]int64)
-	addr := fmt.Sprintf(":%d", port)
-
-	// Create the gRPC server
-	s.srv = grpc.NewServer()
+// Shutdown the grpc server instances, reporting that they are no longer
+// serving to health checks while outstanding pings are completed. Serving
+// returns once the server is stopped and its state has been saved. If the
+// server is still starting, it stops as soon as it is ready instead of serving.
+func (s *PingServer) Shutdown() {
+	// Do not hold the lock while stopping since pending pings require it
+	s.Lock()
+	s.stopping = true
+	hs := s.health
+	servers := s.servers
+	s.servers = nil
+	s.Unlock()
+
+	if hs != nil {
+		hs.Shutdown()
+	}
 
-	// Register the handler object
-	pb.RegisterSecurePingServer(s.srv, s)
+	for _, srv := range servers {
+		srv.GracefulStop()
+	}
+}
 
-	// Create the channel to listen on
-	lis, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("could not listen on %s: %s", addr, err)
+// Stops the background routines of the server once it is no longer serving,
+// saving the state after the outstanding pings have completed.
+func (s *PingServer) cleanup() {
+	if s.snapshots != nil {
+		if err := s.snapshots.Stop(); err != nil {
+			Output("could not save state: %s", err)
+		}
 	}
 
-	// Serve and Listen
-	if err := s.srv.Serve(lis); err != nil {
-		return fmt.Errorf("grpc serve error: %s", err)
+	if s.monitor != nil {
+		s.monitor.Stop()
+	}
+	if s.rotator != nil {
+		s.rotator.Stop()
 	}
+}
+
+// ServeMutualTLS is an alias for Serve. It is mostly here for benchmarking.
+func (s *PingServer) ServeMutualTLS(port uint) error {
+	return s.Serve(port)
+}
 
-	return nil
+// ServeTLS is a helper method for server-side encryption that does not expect
+// client authentication or credentials. It is mostly here for benchmarking.
+func (s *PingServer) ServeTLS(port uint) error {
+	return s.ServeListeners(Listener{"tcp", fmt.Sprintf(":%d", port), SecurityTLS})
+}
+
+// ServeInsecure is a helper method for no server-side encryption.
+// It is mostly here for benchmarking.
+func (s *PingServer) ServeInsecure(port uint) error {
+	return s.ServeListeners(Listener{"tcp", fmt.Sprintf(":%d", port), SecurityInsecure})
 }
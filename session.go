@@ -0,0 +1,125 @@
+package sping
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Session resumption defaults
+const (
+	DefaultSessionCacheSize = 64
+	DefaultTicketKeys       = 3
+)
+
+// The number of full and resumed TLS handshakes completed by this process.
+var tlsHandshakes = expvar.NewMap("tls_handshakes")
+
+// NewSessionCache returns a client session cache that can be shared between
+// connections so that repeated cold pings resume rather than perform a full
+// handshake with the server.
+func NewSessionCache() tls.ClientSessionCache {
+	return tls.NewLRUClientSessionCache(DefaultSessionCacheSize)
+}
+
+// Records whether a completed handshake was resumed or full.
+func countHandshake(info credentials.AuthInfo) {
+	if tlsInfo, ok := info.(credentials.TLSInfo); ok {
+		if tlsInfo.State.DidResume {
+			tlsHandshakes.Add("resumed", 1)
+		} else {
+			tlsHandshakes.Add("full", 1)
+		}
+	}
+}
+
+// TicketKeyRotator periodically replaces the session ticket keys of a server
+// TLS configuration. The newest key is used to encrypt tickets and the
+// previous keys are retained so that recently issued tickets still resume.
+type TicketKeyRotator struct {
+	sync.Mutex
+	Interval time.Duration // how often a new ticket key is generated
+	Keys     int           // the number of keys retained for decryption
+	keys     [][32]byte    // the current keys, newest first
+	tickets  *tls.Config   // holds the keys used to encrypt session tickets
+	stop     chan struct{} // closed to stop the rotation
+	once     sync.Once     // ensures the rotator is only stopped once
+}
+
+// NewTicketKeyRotator creates a rotator with the specified interval that
+// retains the default number of ticket keys.
+func NewTicketKeyRotator(interval time.Duration) *TicketKeyRotator {
+	return &TicketKeyRotator{
+		Interval: interval,
+		Keys:     DefaultTicketKeys,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Rotate generates a new session ticket key and sets it on the configuration,
+// discarding the oldest key if more than the retained number of keys exist.
+func (r *TicketKeyRotator) Rotate() error {
+	r.Lock()
+	defer r.Unlock()
+
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return fmt.Errorf("could not generate session ticket key: %s", err)
+	}
+
+	r.keys = append([][32]byte{key}, r.keys...)
+	if len(r.keys) > r.Keys {
+		r.keys = r.keys[:r.Keys]
+	}
+
+	r.tickets.SetSessionTicketKeys(r.keys)
+	return nil
+}
+
+// Start sets the initial ticket key and then rotates it every interval in a
+// background routine until the rotator is stopped. Because the gRPC
+// credentials copy the configuration they are created with, the keys are not
+// set on conf directly; instead its session tickets are wrapped and unwrapped
+// with the rotated keys, so Start must be called before creating credentials.
+func (r *TicketKeyRotator) Start(conf *tls.Config) error {
+	r.tickets = new(tls.Config)
+	if err := r.Rotate(); err != nil {
+		return err
+	}
+
+	conf.WrapSession = func(state tls.ConnectionState, session *tls.SessionState) ([]byte, error) {
+		return r.tickets.EncryptTicket(state, session)
+	}
+
+	conf.UnwrapSession = func(ticket []byte, state tls.ConnectionState) (*tls.SessionState, error) {
+		return r.tickets.DecryptTicket(ticket, state)
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Rotate(); err != nil {
+					Output("could not rotate session ticket keys: %s", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop rotating the session ticket keys.
+func (r *TicketKeyRotator) Stop() {
+	r.once.Do(func() { close(r.stop) })
+}
@@ -0,0 +1,106 @@
+package sping
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Completes a handshake over a pipe, returning the state of the client after
+// it has received the session tickets of the server.
+func testHandshake(t *testing.T, server, client *tls.Config) tls.ConnectionState {
+	sconn, cconn := net.Pipe()
+	go func() {
+		conn := tls.Server(sconn, server)
+		conn.Handshake()
+		conn.Close()
+	}()
+
+	conn := tls.Client(cconn, client)
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(conn)
+	return conn.ConnectionState()
+}
+
+// Returns the number of handshakes of the kind counted by this process.
+func countedHandshakes(kind string) int64 {
+	if count, ok := tlsHandshakes.Get(kind).(*expvar.Int); ok {
+		return count.Value()
+	}
+	return 0
+}
+
+func TestTicketKeyRotator(t *testing.T) {
+	cert, leaf := testCertificate(t, "localhost", time.Now().Add(time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	rotator := NewTicketKeyRotator(time.Hour)
+	rotator.Keys = 2
+
+	server := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err := rotator.Start(server); err != nil {
+		t.Fatal(err)
+	}
+	defer rotator.Stop()
+
+	// Keep the first ticket issued by the server
+	var ticket []byte
+	wrap := server.WrapSession
+	server.WrapSession = func(state tls.ConnectionState, session *tls.SessionState) ([]byte, error) {
+		wrapped, err := wrap(state, session)
+		if ticket == nil {
+			ticket = wrapped
+		}
+		return wrapped, err
+	}
+
+	client := &tls.Config{RootCAs: pool, ServerName: "localhost", ClientSessionCache: NewSessionCache()}
+	full, resumed := countedHandshakes("full"), countedHandshakes("resumed")
+
+	state := testHandshake(t, server, client)
+	if state.DidResume {
+		t.Fatal("expected the first handshake to be full")
+	}
+	countHandshake(credentials.TLSInfo{State: state})
+
+	if ticket == nil {
+		t.Fatal("expected the server to issue a session ticket")
+	}
+
+	// Tickets wrapped with the previous key still resume after a rotation
+	if err := rotator.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	state = testHandshake(t, server, client)
+	if !state.DidResume {
+		t.Error("expected the session to resume after one rotation")
+	}
+	countHandshake(credentials.TLSInfo{State: state})
+
+	if countedHandshakes("full") != full+1 || countedHandshakes("resumed") != resumed+1 {
+		t.Errorf("expected one full and one resumed handshake to be counted")
+	}
+
+	if session, err := server.UnwrapSession(ticket, tls.ConnectionState{}); err != nil || session == nil {
+		t.Errorf("expected the ticket to unwrap after one rotation got %v", err)
+	}
+
+	// Once the key has been discarded the ticket is rejected
+	if err := rotator.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if session, err := server.UnwrapSession(ticket, tls.ConnectionState{}); err != nil || session != nil {
+		t.Errorf("expected the ticket to be rejected after its key expired got %v", err)
+	}
+}
@@ -25,6 +25,7 @@ func NewClient(dailer Dailer, address, name string, delay int64, limit uint) *Pi
 		Name:             name,
 		Delay:            time.Duration(delay) * time.Millisecond,
 		Limit:            limit,
+		Target:           address,
 		sequence:         0,
 		Connection:       conn,
 		SecurePingClient: pb.NewSecurePingClient(conn),
@@ -0,0 +1,183 @@
+package sping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultSnapshotInterval is how often the server state is saved by default.
+const DefaultSnapshotInterval = 30 * time.Second
+
+// SenderState is the sequence and statistics of the pings received from a
+// single sender, which is persisted so that a server restart does not reset
+// the sequence and mark the next ping of every sender as out of order.
+type SenderState struct {
+	Sequence   int64     `json:"sequence"`     // the number of pings received in the current sequence
+	Received   uint64    `json:"received"`     // the total number of pings received
+	OutOfOrder uint64    `json:"out_of_order"` // the number of pings that were not in sequence
+	FirstSeen  time.Time `json:"first_seen"`   // when the first ping was received
+	LastSeen   time.Time `json:"last_seen"`    // when the last ping was received
+}
+
+// The file format of the persisted server state.
+type stateFile struct {
+	Saved   time.Time               `json:"saved"`
+	Senders map[string]*SenderState `json:"senders"`
+}
+
+// LoadState reads the per-sender state saved at the path, returning an empty
+// state if the file does not exist yet.
+func LoadState(path string) (map[string]*SenderState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return make(map[string]*SenderState), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read state: %s", err)
+	}
+
+	var state stateFile
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("could not parse state %s: %s", path, err)
+	}
+
+	if state.Senders == nil {
+		state.Senders = make(map[string]*SenderState)
+	}
+	return state.Senders, nil
+}
+
+// SaveState writes the per-sender state to the path, replacing the previous
+// state atomically so that a crash while saving does not corrupt it.
+func SaveState(path string, senders map[string]*SenderState) error {
+	data, err := json.MarshalIndent(&stateFile{Saved: time.Now(), Senders: senders}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal state: %s", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not save state: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not save state: %s", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not save state: %s", err)
+	}
+	return nil
+}
+
+// Snapshotter periodically saves the state of a server in the background.
+type Snapshotter struct {
+	Interval time.Duration // how often the state is saved
+	save     func() error  // saves the state
+	stop     chan struct{} // closed to stop the snapshots
+	done     chan struct{} // closed when the snapshot routine has returned
+	once     sync.Once     // ensures the snapshotter is only stopped once
+}
+
+// NewSnapshotter creates a snapshotter that calls save every interval.
+func NewSnapshotter(interval time.Duration, save func() error) *Snapshotter {
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+
+	return &Snapshotter{
+		Interval: interval,
+		save:     save,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start saving the state every interval until the snapshotter is stopped.
+func (s *Snapshotter) Start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.save(); err != nil {
+					Output("could not snapshot state: %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop the periodic snapshots and save the state one final time.
+func (s *Snapshotter) Stop() error {
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.save()
+	})
+	return err
+}
+
+// Loads the state of the server from the state file if there is one.
+func (s *PingServer) loadState() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.StateFile == "" {
+		s.senders = make(map[string]*SenderState)
+		return nil
+	}
+
+	var err error
+	if s.senders, err = LoadState(s.StateFile); err != nil {
+		return err
+	}
+
+	if len(s.senders) > 0 {
+		Output("loaded the state of %d senders from %s", len(s.senders), s.StateFile)
+	}
+	return nil
+}
+
+// Saves a copy of the state of the server to the state file.
+func (s *PingServer) saveState() error {
+	s.Lock()
+	senders := make(map[string]*SenderState, len(s.senders))
+	for name, state := range s.senders {
+		copied := *state
+		senders[name] = &copied
+	}
+	s.Unlock()
+
+	return SaveState(s.StateFile, senders)
+}
+
+// Senders returns a copy of the state of every sender the server has seen.
+func (s *PingServer) Senders() map[string]SenderState {
+	s.Lock()
+	defer s.Unlock()
+
+	senders := make(map[string]SenderState, len(s.senders))
+	for name, state := range s.senders {
+		senders[name] = *state
+	}
+	return senders
+}
@@ -0,0 +1,61 @@
+package sping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+)
+
+func TestStateRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	if senders, err := LoadState(path); err != nil || len(senders) != 0 {
+		t.Fatalf("expected empty state before the first save, got %v (%v)", senders, err)
+	}
+
+	logmsgs = false
+	echo := func(server *PingServer, sseq int64) *pb.Pong {
+		pong, err := server.Echo(context.Background(), &pb.Ping{Sender: "tester", Sseq: sseq})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pong
+	}
+
+	// Receive two pings then save the state as if shutting down
+	server := &PingServer{StateFile: path}
+	if err = server.loadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	echo(server, 1)
+	echo(server, 2)
+	if err = server.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restarted server continues the sequence of the sender
+	restarted := &PingServer{StateFile: path}
+	if err = restarted.loadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pong := echo(restarted, 3); !pong.Success || pong.Rseq != 3 {
+		t.Errorf("expected ping 3 to be in sequence after a restart, got %v", pong)
+	}
+
+	state := restarted.Senders()["tester"]
+	if state.Received != 3 || state.OutOfOrder != 0 || state.FirstSeen.IsZero() {
+		t.Errorf("unexpected sender state after restart %+v", state)
+	}
+}
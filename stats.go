@@ -0,0 +1,297 @@
+package sping
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// OffsetGain is the weight of each new sample in the smoothed clock offset,
+// the same exponential smoothing used for the RFC 6298 RTT estimate.
+const OffsetGain = 0.125
+
+// JitterGain is the weight of each new sample in the smoothed interarrival
+// jitter as specified in RFC 3550 section 6.4.1.
+const JitterGain = 1.0 / 16
+
+// The round trip times are counted in buckets that are each RTTBucketGrowth
+// times wider than the previous one starting from RTTBucketMin, so that the
+// percentiles are within 1% of the actual round trip time with a fixed amount
+// of memory however long the client runs.
+const (
+	RTTBucketMin    = time.Microsecond
+	RTTBucketGrowth = 1.02
+	rttBuckets      = 1200 // up to about 6 hours
+)
+
+// Stats accumulates the results of the pings sent by a PingClient.
+type Stats struct {
+	sync.Mutex
+	Sent       uint          // the number of pings sent
+	Received   uint          // the number of pongs received
+	Corrupted  uint          // the number of pongs with corrupted payloads
+	OutOfOrder uint          // the number of pings the server received out of sequence
+	Throttled  uint          // the number of pings rejected by the server rate limit
+	MinRTT     time.Duration // the fastest round trip time
+	MaxRTT     time.Duration // the slowest round trip time
+	TotalRTT   time.Duration // the sum of all round trip times
+	Offset     time.Duration // the smoothed server clock offset estimate
+	Jitter     time.Duration // the RFC 3550 smoothed interarrival jitter
+	IPDV       time.Duration // the delay variation of the last two pongs
+	MaxIPDV    time.Duration // the largest absolute delay variation
+	TotalIPDV  time.Duration // the sum of the absolute delay variations
+	offsets    uint          // the number of clock offset samples
+	firstRTT   time.Duration // the round trip time of the first pong
+	lastRTT    time.Duration // the round trip time of the previous pong
+	rtts       []uint64      // the number of round trip times in each bucket
+}
+
+// Send records that a ping was sent.
+func (s *Stats) Send() {
+	s.Lock()
+	defer s.Unlock()
+	s.Sent++
+}
+
+// Corrupt records that a received pong had a corrupted payload.
+func (s *Stats) Corrupt() {
+	s.Lock()
+	defer s.Unlock()
+	s.Corrupted++
+}
+
+// Misordered records that the server received a ping out of sequence.
+func (s *Stats) Misordered() {
+	s.Lock()
+	defer s.Unlock()
+	s.OutOfOrder++
+}
+
+// Throttle records that the server rejected a ping because of its rate limit.
+func (s *Stats) Throttle() {
+	s.Lock()
+	defer s.Unlock()
+	s.Throttled++
+}
+
+// Lost returns the number of pings that did not receive a pong.
+func (s *Stats) Lost() uint {
+	s.Lock()
+	defer s.Unlock()
+	return s.Sent - s.Received
+}
+
+// Update the stats with the round trip time of a pong and its exchange
+// timestamps, if the server provided them.
+func (s *Stats) Update(rtt time.Duration, exchange *Exchange) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Received++
+	s.TotalRTT += rtt
+	if s.MinRTT == 0 || rtt < s.MinRTT {
+		s.MinRTT = rtt
+	}
+	if rtt > s.MaxRTT {
+		s.MaxRTT = rtt
+	}
+
+	// The difference in round trip times of consecutive pongs is used as
+	// the transit time difference D since the clocks are not synchronized.
+	if s.Received > 1 {
+		s.IPDV = rtt - s.lastRTT
+		abs := s.IPDV
+		if abs < 0 {
+			abs = -abs
+		}
+
+		s.Jitter += time.Duration(JitterGain * float64(abs-s.Jitter))
+		s.TotalIPDV += abs
+		if abs > s.MaxIPDV {
+			s.MaxIPDV = abs
+		}
+	}
+	s.lastRTT = rtt
+	s.count(rtt)
+
+	if exchange != nil {
+		s.addOffset(exchange.Offset())
+	}
+}
+
+// Merge adds the stats of pings sent after the pings of these stats, e.g. by
+// another run of the client, as though they were sent by a single run. The
+// smoothed jitter and clock offset are those of the later pings.
+func (s *Stats) Merge(other *Stats) {
+	s.Lock()
+	defer s.Unlock()
+	other.Lock()
+	defer other.Unlock()
+
+	s.Sent += other.Sent
+	s.Corrupted += other.Corrupted
+	s.OutOfOrder += other.OutOfOrder
+	s.Throttled += other.Throttled
+
+	if other.Received == 0 {
+		return
+	}
+
+	// The delay variation between the last pong and the first later pong
+	if s.Received > 0 {
+		abs := other.firstRTT - s.lastRTT
+		if abs < 0 {
+			abs = -abs
+		}
+
+		s.TotalIPDV += abs
+		if abs > s.MaxIPDV {
+			s.MaxIPDV = abs
+		}
+	}
+
+	if s.MinRTT == 0 || other.MinRTT < s.MinRTT {
+		s.MinRTT = other.MinRTT
+	}
+	if other.MaxRTT > s.MaxRTT {
+		s.MaxRTT = other.MaxRTT
+	}
+	if other.MaxIPDV > s.MaxIPDV {
+		s.MaxIPDV = other.MaxIPDV
+	}
+
+	s.Received += other.Received
+	s.TotalRTT += other.TotalRTT
+	s.TotalIPDV += other.TotalIPDV
+	s.Jitter, s.IPDV = other.Jitter, other.IPDV
+	s.lastRTT = other.lastRTT
+	if s.rtts == nil {
+		s.rtts = make([]uint64, rttBuckets)
+		s.firstRTT = other.firstRTT
+	}
+	for i, count := range other.rtts {
+		s.rtts[i] += count
+	}
+
+	if other.offsets > 0 {
+		s.Offset = other.Offset
+		s.offsets += other.offsets
+	}
+}
+
+// Counts the round trip time in its bucket, must hold the lock.
+func (s *Stats) count(rtt time.Duration) {
+	if s.rtts == nil {
+		s.rtts = make([]uint64, rttBuckets)
+		s.firstRTT = rtt
+	}
+
+	bucket := 0
+	if rtt > RTTBucketMin {
+		bucket = int(math.Log(float64(rtt)/float64(RTTBucketMin)) / math.Log(RTTBucketGrowth))
+	}
+
+	if bucket >= rttBuckets {
+		bucket = rttBuckets - 1
+	}
+	s.rtts[bucket]++
+}
+
+// Adds a clock offset sample to the smoothed offset, must hold the lock.
+func (s *Stats) addOffset(offset time.Duration) {
+	if s.offsets == 0 {
+		s.Offset = offset
+	} else {
+		s.Offset += time.Duration(OffsetGain * float64(offset-s.Offset))
+	}
+	s.offsets++
+}
+
+// MeanRTT returns the average round trip time of the received pongs.
+func (s *Stats) MeanRTT() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.Received == 0 {
+		return 0
+	}
+	return s.TotalRTT / time.Duration(s.Received)
+}
+
+// Percentile returns the round trip time that p percent of the received pongs
+// were faster than or equal to, using the nearest rank method. The percentile
+// is approximate, within 1% of the actual round trip time, except for the
+// fastest and slowest pongs.
+func (s *Stats) Percentile(p float64) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.Received == 0 {
+		return 0
+	}
+
+	n := float64(s.Received)
+	rank := uint64(p / 100 * n)
+	if float64(rank) < p/100*n {
+		rank++
+	}
+
+	switch {
+	case rank <= 1:
+		return s.MinRTT
+	case rank >= uint64(s.Received):
+		return s.MaxRTT
+	}
+
+	// The middle of the bucket of the ranked round trip time
+	var seen uint64
+	for bucket, count := range s.rtts {
+		if seen += count; seen >= rank {
+			rtt := time.Duration(float64(RTTBucketMin) * math.Pow(RTTBucketGrowth, float64(bucket)+0.5))
+			switch {
+			case rtt < s.MinRTT:
+				return s.MinRTT
+			case rtt > s.MaxRTT:
+				return s.MaxRTT
+			}
+			return rtt
+		}
+	}
+	return s.MaxRTT
+}
+
+// Summary returns a human readable description of the stats.
+func (s *Stats) Summary() []string {
+	mean := s.MeanRTT()
+	lost := s.Lost()
+	p50, p99 := s.Percentile(50), s.Percentile(99)
+
+	s.Lock()
+	defer s.Unlock()
+
+	lines := []string{
+		fmt.Sprintf(
+			"%d pings sent, %d pongs received, %d lost, %d corrupted, %d out of order",
+			s.Sent, s.Received, lost, s.Corrupted, s.OutOfOrder,
+		),
+		fmt.Sprintf("rtt min/avg/max = %s/%s/%s, p50/p99 = %s/%s", s.MinRTT, mean, s.MaxRTT, p50, p99),
+	}
+
+	if s.Throttled > 0 {
+		lines[0] += fmt.Sprintf(", %d rate limited", s.Throttled)
+	}
+
+	if s.Received > 1 {
+		meanIPDV := s.TotalIPDV / time.Duration(s.Received-1)
+		lines = append(lines, fmt.Sprintf(
+			"jitter = %s, ipdv avg/max = %s/%s", s.Jitter, meanIPDV, s.MaxIPDV,
+		))
+	}
+
+	if s.offsets > 0 {
+		lines = append(lines, fmt.Sprintf("smoothed clock offset = %s", s.Offset))
+	}
+
+	return lines
+}
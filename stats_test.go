@@ -0,0 +1,65 @@
+package sping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsJitter(t *testing.T) {
+	stats := new(Stats)
+	for _, rtt := range []time.Duration{100, 116, 100, 132} {
+		stats.Send()
+		stats.Update(rtt*time.Millisecond, nil)
+	}
+
+	// D = 16, -16, 32: J = 1, then 1.9375, then 3.81640625 milliseconds
+	if expected := 3816406 * time.Nanosecond; stats.Jitter < expected-time.Microsecond || stats.Jitter > expected+time.Microsecond {
+		t.Errorf("expected jitter of %s got %s", expected, stats.Jitter)
+	}
+
+	if stats.IPDV != 32*time.Millisecond || stats.MaxIPDV != 32*time.Millisecond {
+		t.Errorf("expected last and max ipdv of 32ms got %s and %s", stats.IPDV, stats.MaxIPDV)
+	}
+
+	if stats.MinRTT != 100*time.Millisecond || stats.MaxRTT != 132*time.Millisecond || stats.MeanRTT() != 112*time.Millisecond {
+		t.Errorf("unexpected rtt min/avg/max %s/%s/%s", stats.MinRTT, stats.MeanRTT(), stats.MaxRTT)
+	}
+
+	if stats.Lost() != 0 {
+		t.Errorf("expected no lost pings, got %d", stats.Lost())
+	}
+}
+
+func TestStatsMerge(t *testing.T) {
+	stats, other := new(Stats), new(Stats)
+	for _, rtt := range []time.Duration{100, 116} {
+		stats.Send()
+		stats.Update(rtt*time.Millisecond, nil)
+	}
+
+	for _, rtt := range []time.Duration{100, 132} {
+		other.Send()
+		other.Update(rtt*time.Millisecond, nil)
+	}
+	other.Send()
+	other.Misordered()
+
+	// The merged stats are those of all of the pings sent in order
+	stats.Merge(other)
+	if stats.Sent != 5 || stats.Received != 4 || stats.OutOfOrder != 1 {
+		t.Errorf("unexpected counts of the merged stats %d sent %d received %d out of order", stats.Sent, stats.Received, stats.OutOfOrder)
+	}
+
+	if stats.MinRTT != 100*time.Millisecond || stats.MaxRTT != 132*time.Millisecond || stats.MeanRTT() != 112*time.Millisecond {
+		t.Errorf("unexpected rtt min/avg/max %s/%s/%s", stats.MinRTT, stats.MeanRTT(), stats.MaxRTT)
+	}
+
+	// D = 16, -16, 32 including the pongs either side of the merge
+	if stats.TotalIPDV != 64*time.Millisecond || stats.MaxIPDV != 32*time.Millisecond {
+		t.Errorf("expected total and max ipdv of 64ms and 32ms got %s and %s", stats.TotalIPDV, stats.MaxIPDV)
+	}
+
+	if p99 := stats.Percentile(99); p99 != 132*time.Millisecond {
+		t.Errorf("expected the p99 of all of the pongs got %s", p99)
+	}
+}
@@ -0,0 +1,97 @@
+package sping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thresholds are the limits on the stats of a run that are acceptable, e.g.
+// for health checks in scripts. Negative limits are not checked.
+type Thresholds struct {
+	MaxLoss       float64       // the percentage of pings lost
+	MaxP99        time.Duration // the 99th percentile round trip time
+	MaxOutOfOrder int           // the number of pings received out of sequence
+}
+
+// NoThresholds returns thresholds that do not check any of the stats.
+func NoThresholds() *Thresholds {
+	return &Thresholds{MaxLoss: -1, MaxP99: -1, MaxOutOfOrder: -1}
+}
+
+// Check returns an error describing every threshold that the stats breach.
+func (t *Thresholds) Check(stats *Stats) error {
+	var breaches []string
+
+	if t.MaxLoss >= 0 {
+		stats.Lock()
+		sent, received := stats.Sent, stats.Received
+		stats.Unlock()
+
+		if sent > 0 {
+			if loss := 100 * float64(sent-received) / float64(sent); loss > t.MaxLoss {
+				breaches = append(breaches, fmt.Sprintf("loss %0.1f%% exceeds %0.1f%%", loss, t.MaxLoss))
+			}
+		}
+	}
+
+	if t.MaxP99 >= 0 {
+		if p99 := stats.Percentile(99); p99 > t.MaxP99 {
+			breaches = append(breaches, fmt.Sprintf("p99 rtt %s exceeds %s", p99, t.MaxP99))
+		}
+	}
+
+	if t.MaxOutOfOrder >= 0 {
+		stats.Lock()
+		misordered := stats.OutOfOrder
+		stats.Unlock()
+
+		if misordered > uint(t.MaxOutOfOrder) {
+			breaches = append(breaches, fmt.Sprintf("%d pings out of order exceeds %d", misordered, t.MaxOutOfOrder))
+		}
+	}
+
+	if len(breaches) > 0 {
+		return fmt.Errorf("thresholds breached: %s", strings.Join(breaches, ", "))
+	}
+	return nil
+}
+
+// ParsePercent parses a percentage such as "5%" or "5", returning -1 if the
+// string is empty.
+func ParsePercent(s string) (float64, error) {
+	if s == "" {
+		return -1, nil
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return percent, nil
+}
+
+// PingError is returned when a run stops because the client could not ping
+// the server, as opposed to an invalid configuration of the client.
+type PingError struct {
+	Err error // the error connecting to or pinging the server
+}
+
+// Error returns the message of the underlying error.
+func (e *PingError) Error() string {
+	return e.Err.Error()
+}
+
+// TLS returns true if the connection failed because of the TLS handshake,
+// e.g. because the server certificate is not trusted or has expired. gRPC only
+// reports the handshake error as the message of the connection error.
+func (e *PingError) TLS() bool {
+	msg := e.Err.Error()
+	for _, cause := range []string{"authentication handshake failed", "x509: ", "tls: "} {
+		if strings.Contains(msg, cause) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package sping
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestPercentile(t *testing.T) {
+	stats := new(Stats)
+	if p99 := stats.Percentile(99); p99 != 0 {
+		t.Errorf("expected no p99 without pongs, got %s", p99)
+	}
+
+	// Update in reverse so that the percentile must sort the round trip times
+	for i := 100; i > 0; i-- {
+		stats.Send()
+		stats.Update(time.Duration(i)*time.Millisecond, nil)
+	}
+
+	tests := map[float64]time.Duration{
+		0: time.Millisecond, 50: 50 * time.Millisecond, 99: 99 * time.Millisecond,
+		99.5: 100 * time.Millisecond, 100: 100 * time.Millisecond,
+	}
+
+	// Percentiles other than the fastest and slowest pongs are within 1%
+	for p, expected := range tests {
+		if actual := stats.Percentile(p); actual < expected*99/100 || actual > expected*101/100 {
+			t.Errorf("expected p%v of %s, got %s", p, expected, actual)
+		}
+	}
+
+	if min, max := stats.Percentile(0), stats.Percentile(100); min != time.Millisecond || max != 100*time.Millisecond {
+		t.Errorf("expected the exact fastest and slowest pongs got %s and %s", min, max)
+	}
+
+	// The round trip times take a fixed amount of memory
+	for i := 0; i < 10000; i++ {
+		stats.Update(time.Duration(i)*time.Microsecond, nil)
+	}
+
+	if len(stats.rtts) != rttBuckets {
+		t.Errorf("expected %d round trip time buckets got %d", rttBuckets, len(stats.rtts))
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	stats := new(Stats)
+	for i := 1; i <= 10; i++ {
+		stats.Send()
+		if i == 10 {
+			continue
+		}
+		stats.Update(time.Duration(i)*time.Millisecond, nil)
+	}
+	stats.Misordered()
+
+	if err := NoThresholds().Check(stats); err != nil {
+		t.Errorf("expected no thresholds to pass, got %s", err)
+	}
+
+	thresholds := &Thresholds{MaxLoss: 10, MaxP99: 9 * time.Millisecond, MaxOutOfOrder: 1}
+	if err := thresholds.Check(stats); err != nil {
+		t.Errorf("expected stats at the thresholds to pass, got %s", err)
+	}
+
+	thresholds = &Thresholds{MaxLoss: 5, MaxP99: 5 * time.Millisecond, MaxOutOfOrder: 0}
+	err := thresholds.Check(stats)
+	if err == nil {
+		t.Fatal("expected the thresholds to be breached")
+	}
+
+	for _, breach := range []string{"loss 10.0% exceeds 5.0%", "p99 rtt 9ms exceeds 5ms", "1 pings out of order exceeds 0"} {
+		if !strings.Contains(err.Error(), breach) {
+			t.Errorf("expected %q in %q", breach, err)
+		}
+	}
+}
+
+func TestSweepThresholds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Every ping takes at least as long as the injected delay
+	logmsgs = false
+	server := NewServer()
+	if server.Faults, err = NewFaultInjector(Faults{Delay: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "sping.sock")
+	go server.ServeListeners(Listener{"unix", path, SecurityInsecure})
+	defer server.Shutdown()
+
+	client := NewClient(Insecure, unixPrefix+path, "sweeper", 1, 2)
+	defer client.Connection.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Wait for the server to start before the sweep
+	if _, err = client.Echo(ctx, client.Next(), grpc.FailFast(false)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = client.RunSweep(ctx, []int{0, 64, 256}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stats of the client are those of the pings of every payload size
+	stats := client.Stats()
+	if stats.Sent != 6 || stats.Received != 6 {
+		t.Errorf("expected 6 pings across the sweep got %d sent and %d received", stats.Sent, stats.Received)
+	}
+
+	thresholds := &Thresholds{MaxLoss: -1, MaxP99: 10 * time.Millisecond, MaxOutOfOrder: -1}
+	if err = thresholds.Check(stats); err == nil || !strings.Contains(err.Error(), "p99 rtt") {
+		t.Errorf("expected the sweep to breach the p99 threshold got %v", err)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := map[string]float64{"": -1, "5%": 5, "0.5": 0.5, " 100% ": 100}
+	for s, expected := range tests {
+		if actual, err := ParsePercent(s); err != nil || actual != expected {
+			t.Errorf("expected %q to parse as %v, got %v (%v)", s, expected, actual, err)
+		}
+	}
+
+	for _, s := range []string{"five", "-1%", "101%"} {
+		if _, err := ParsePercent(s); err == nil {
+			t.Errorf("expected %q to be an invalid percentage", s)
+		}
+	}
+}
+
+func TestPingErrorTLS(t *testing.T) {
+	tls := &PingError{errors.New(`connection error: desc = "transport: authentication handshake failed: x509: certificate has expired or is not yet valid"`)}
+	if !tls.TLS() {
+		t.Error("expected a handshake failure to be a tls error")
+	}
+
+	refused := &PingError{errors.New(`connection error: desc = "transport: error while dialing: dial tcp 127.0.0.1:3264: connect: connection refused"`)}
+	if refused.TLS() {
+		t.Error("expected a refused connection not to be a tls error")
+	}
+}
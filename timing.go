@@ -0,0 +1,272 @@
+package sping
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDialTimeout bounds how long a cold ping waits to connect and complete
+// the handshake, even if the ping itself has no timeout or deadline.
+const DefaultDialTimeout = 10 * time.Second
+
+// Timing records how long each phase of a cold ping took: establishing the
+// TCP connection, completing the TLS handshake and the Echo RPC itself.
+type Timing struct {
+	sync.Mutex
+	Connect   time.Duration // time to establish the TCP connection
+	Handshake time.Duration // time to complete the TLS handshake
+	RPC       time.Duration // round trip time of the Echo RPC
+	Resumed   bool          // if the TLS session was resumed
+	TLS       *TLSDetails   // the negotiated TLS details of the connection
+}
+
+// Total returns the sum of the time spent in all phases of the ping.
+func (t *Timing) Total() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	return t.Connect + t.Handshake + t.RPC
+}
+
+// String returns a human readable description of the phase timings.
+func (t *Timing) String() string {
+	t.Lock()
+	defer t.Unlock()
+	handshake := "full"
+	if t.Resumed {
+		handshake = "resumed"
+	}
+
+	return fmt.Sprintf(
+		"connect %s %s handshake %s rpc %s",
+		t.Connect, handshake, t.Handshake, t.RPC,
+	)
+}
+
+// dial is passed to grpc.WithDialer to timestamp the TCP connection phase.
+func (t *Timing) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+
+	t.Lock()
+	t.Connect = time.Since(start)
+	t.Unlock()
+
+	return conn, err
+}
+
+// credentials wraps the transport credentials to timestamp the TLS handshake.
+func (t *Timing) credentials(creds credentials.TransportCredentials) credentials.TransportCredentials {
+	return &timedCredentials{TransportCredentials: creds, timing: t}
+}
+
+// timedCredentials records the duration of the client handshake of the
+// wrapped credentials on the associated Timing.
+type timedCredentials struct {
+	credentials.TransportCredentials
+	timing *Timing
+}
+
+// ClientHandshake implements credentials.TransportCredentials
+func (c *timedCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	start := time.Now()
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+
+	c.timing.Lock()
+	c.timing.Handshake = time.Since(start)
+	if tlsInfo, ok := info.(credentials.TLSInfo); ok {
+		c.timing.Resumed = tlsInfo.State.DidResume
+	}
+	c.timing.Unlock()
+
+	countHandshake(info)
+
+	if err != nil {
+		return nil, nil, handshakeError{err}
+	}
+	return conn, info, nil
+}
+
+// handshakeError marks a failed handshake as permanent, since gRPC retries
+// errors that do not say otherwise, so that blocking dials fail immediately.
+type handshakeError struct {
+	error
+}
+
+// Temporary is false since the handshake fails the same way when retried.
+func (e handshakeError) Temporary() bool {
+	return false
+}
+
+// Clone implements credentials.TransportCredentials
+func (c *timedCredentials) Clone() credentials.TransportCredentials {
+	return &timedCredentials{
+		TransportCredentials: c.TransportCredentials.Clone(),
+		timing:               c.timing,
+	}
+}
+
+// ColdPing creates a new mutual TLS connection to the server, sends a single
+// Ping request and closes the connection, returning the time spent in each
+// phase of the exchange. Unlike Ping, the dial blocks until the connection is
+// established so that connection setup is not counted as part of the RPC.
+func (c *PingClient) ColdPing(ctx context.Context, addr string) (*pb.Pong, *Timing, error) {
+	timing := new(Timing)
+
+	// Create the mutual TLS configuration
+	conf, err := mutualTLSConfig(c.Certs, c.Policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	conf.ClientSessionCache = c.SessionCache
+
+	// Create the TLS credentials for transport wrapped by the timer
+	creds := timing.credentials(credentials.NewTLS(conf))
+
+	// Pings that cannot connect are lost like pings without a pong
+	c.Stats().Send()
+
+	// Block until the connection is established to time the setup phases,
+	// failing immediately if the server refuses the connection or handshake.
+	dctx, cancel := context.WithTimeout(ctx, DefaultDialTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(
+		dctx, addr, grpc.WithTransportCredentials(creds),
+		grpc.WithDialer(timing.dial), grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+	)
+	if err != nil {
+		return nil, nil, &PingError{fmt.Errorf("could not connect to %s: %s", addr, err)}
+	}
+	defer conn.Close()
+
+	var p peer.Peer
+	client := pb.NewSecurePingClient(conn)
+	start := time.Now()
+	pong, err := c.tracedEcho(ctx, client, c.Next(), grpc.Peer(&p))
+	if err != nil {
+		// Rate limit and timeout errors keep their status so that the run
+		// can back off or count the ping as lost
+		if _, limited := RetryDelay(err); limited || status.Code(err) == codes.DeadlineExceeded {
+			return nil, timing, err
+		}
+		return nil, nil, &PingError{fmt.Errorf("failed echo RPC call: %s", err)}
+	}
+
+	timing.Lock()
+	timing.RPC = time.Since(start)
+	timing.TLS = PeerTLSDetails(&p)
+	timing.Unlock()
+
+	c.Stats().Update(timing.RPC, NewExchange(pong, time.Now()))
+	if err := c.Verify(pong); err != nil {
+		c.Stats().Corrupt()
+		c.output("%s", err)
+	}
+
+	if !pong.Success {
+		c.Stats().Misordered()
+	}
+
+	// Every cold ping negotiates a new connection, so output each one
+	if c.ShowTLS {
+		outputTLS(c.output, "tls connection to", p.Addr, PeerTLSDetails(&p))
+	}
+
+	return pong, timing, nil
+}
+
+// RunCold pings the server with a new connection for every ping, reporting
+// the connection setup time alongside the RPC round trip time and the number
+// of full and resumed handshakes when the run is complete. The run stops under
+// the same conditions as Run.
+func (c *PingClient) RunCold(ctx context.Context, addr string) error {
+
+	// Warn if the client certificates are close to expiring
+	if len(c.CertFiles) > 0 {
+		monitor, err := monitorCerts(c.ExpiryWarning, c.CertFiles...)
+		if err != nil {
+			return err
+		}
+		defer monitor.Stop()
+	}
+
+	if c.Target == "" {
+		c.Target = addr
+	}
+
+	var ok bool
+	var handshakes, resumed uint
+	next := time.Now()
+	scheduler := c.schedule()
+
+	// Stops sending pings after the duration or the limit is reached
+	sending, stop := c.sending(ctx)
+	defer stop()
+
+	for idx := uint(1); ; idx++ {
+		if next, ok = wait(sending, scheduler, next); !ok {
+			for _, line := range c.Stats().Summary() {
+				c.output("%s", line)
+			}
+			c.output("%d full and %d resumed handshakes", handshakes-resumed, resumed)
+			return nil
+		}
+
+		sent := time.Now()
+		pctx, cancel := c.pingContext(ctx)
+		pong, timing, err := c.ColdPing(pctx, addr)
+		returned := time.Now()
+		cancel()
+
+		if idx == c.Limit {
+			stop()
+		}
+
+		if err != nil {
+			// The run was stopped while connecting or awaiting the pong
+			if ctx.Err() != nil {
+				continue
+			}
+
+			// Pings rejected by the rate limit are lost and the run backs off
+			if delay, limited := c.rateLimited(sent, err); limited {
+				if retry := time.Now().Add(delay); retry.After(next) {
+					next = retry
+				}
+				continue
+			}
+
+			// Pings that time out are lost but the run continues, unless the
+			// handshake failed since every following ping would also fail.
+			if perr, ok := err.(*PingError); (!ok || !perr.TLS()) && timedOut(pctx, err) {
+				c.record(sent, 0, nil, nil, context.DeadlineExceeded)
+				c.output("ping %d timed out after %s", c.sequence, returned.Sub(sent))
+				continue
+			}
+
+			c.record(sent, 0, nil, nil, err)
+			return err
+		}
+
+		c.record(sent, timing.RPC, pong, NewExchange(pong, returned), nil)
+		handshakes++
+
+		if timing.Resumed {
+			resumed++
+		}
+
+		c.output("ping %d/%d took %s (%s)", pong.Sseq, pong.Rseq, timing.Total(), timing)
+	}
+}
@@ -0,0 +1,136 @@
+package sping
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Creates a self-signed certificate for localhost that expires at notAfter.
+func testCertificate(t *testing.T, name string, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+// Serves TLS handshakes with the certificate on an ephemeral port until the
+// test is complete, returning the address of the listener.
+func testTLSListener(t *testing.T, cert tls.Certificate) string {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	t.Cleanup(func() { lis.Close() })
+	return lis.Addr().String()
+}
+
+func TestTimingString(t *testing.T) {
+	timing := &Timing{Connect: time.Millisecond, Handshake: 2 * time.Millisecond, RPC: 3 * time.Millisecond}
+	if total := timing.Total(); total != 6*time.Millisecond {
+		t.Errorf("expected a total of 6ms got %s", total)
+	}
+
+	if s := timing.String(); s != "connect 1ms full handshake 2ms rpc 3ms" {
+		t.Errorf("unexpected timing %q", s)
+	}
+
+	timing.Resumed = true
+	if s := timing.String(); !strings.Contains(s, "resumed handshake") {
+		t.Errorf("expected a resumed handshake got %q", s)
+	}
+}
+
+func TestTimedHandshake(t *testing.T) {
+	cert, leaf := testCertificate(t, "localhost", time.Now().Add(time.Hour))
+	addr := testTLSListener(t, cert)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	timing := new(Timing)
+	creds := timing.credentials(credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "localhost"}))
+
+	conn, err := timing.dial(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, info, err := creds.ClientHandshake(context.Background(), addr, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.(credentials.TLSInfo).State.HandshakeComplete {
+		t.Error("expected the handshake to be complete")
+	}
+
+	if timing.Connect <= 0 || timing.Handshake <= 0 || timing.Resumed {
+		t.Errorf("unexpected timing of a full handshake: %s", timing)
+	}
+
+	// Failed handshakes are not retried by gRPC
+	untrusted := timing.credentials(credentials.NewTLS(&tls.Config{RootCAs: x509.NewCertPool(), ServerName: "localhost"}))
+	if conn, err = timing.dial(addr, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, _, err = untrusted.ClientHandshake(context.Background(), addr, conn)
+	if err == nil {
+		t.Fatal("expected the handshake with an untrusted certificate to fail")
+	}
+
+	if temp, ok := err.(interface{ Temporary() bool }); !ok || temp.Temporary() {
+		t.Errorf("expected a permanent handshake error got %v", err)
+	}
+}
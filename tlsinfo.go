@@ -0,0 +1,154 @@
+package sping
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// TLSDetails describes the negotiated parameters of a TLS connection.
+type TLSDetails struct {
+	Version      string        // the negotiated TLS protocol version
+	CipherSuite  string        // the negotiated cipher suite
+	ALPN         string        // the negotiated application protocol
+	Resumed      bool          // whether the session was resumed
+	Certificates []CertDetails // the certificate chain presented by the peer
+}
+
+// CertDetails describes a single certificate in a peer's chain.
+type CertDetails struct {
+	Subject  string    // the distinguished name of the certificate subject
+	Issuer   string    // the distinguished name of the certificate issuer
+	SANs     []string  // the DNS names, IP addresses and emails of the subject
+	NotAfter time.Time // the expiration time of the certificate
+}
+
+// NewTLSDetails creates the details from a TLS connection state.
+func NewTLSDetails(state tls.ConnectionState) *TLSDetails {
+	details := &TLSDetails{
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		Resumed:      state.DidResume,
+		Certificates: make([]CertDetails, 0, len(state.PeerCertificates)),
+	}
+
+	for _, cert := range state.PeerCertificates {
+		details.Certificates = append(details.Certificates, NewCertDetails(cert))
+	}
+
+	return details
+}
+
+// NewCertDetails creates the details from an x509 certificate.
+func NewCertDetails(cert *x509.Certificate) CertDetails {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+
+	return CertDetails{
+		Subject:  cert.Subject.String(),
+		Issuer:   cert.Issuer.String(),
+		SANs:     sans,
+		NotAfter: cert.NotAfter,
+	}
+}
+
+// PeerTLSDetails extracts the TLS details from the AuthInfo of a gRPC peer,
+// returning nil if the peer did not connect with TLS.
+func PeerTLSDetails(p *peer.Peer) *TLSDetails {
+	if p == nil {
+		return nil
+	}
+
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		return NewTLSDetails(info.State)
+	}
+	return nil
+}
+
+// Lines returns a human readable description of the TLS details, one line
+// per negotiated parameter and one line per certificate in the chain.
+func (d *TLSDetails) Lines() []string {
+	alpn := d.ALPN
+	if alpn == "" {
+		alpn = "none"
+	}
+
+	lines := []string{
+		fmt.Sprintf("version: %s", d.Version),
+		fmt.Sprintf("cipher suite: %s", d.CipherSuite),
+		fmt.Sprintf("alpn: %s", alpn),
+		fmt.Sprintf("resumed: %t", d.Resumed),
+	}
+
+	for i, cert := range d.Certificates {
+		lines = append(lines, fmt.Sprintf("certificate %d: %s", i, cert))
+	}
+
+	return lines
+}
+
+// String returns a description of the certificate on a single line.
+func (c CertDetails) String() string {
+	sans := "none"
+	if len(c.SANs) > 0 {
+		sans = strings.Join(c.SANs, ", ")
+	}
+
+	return fmt.Sprintf(
+		"subject %q issuer %q sans [%s] expires %s",
+		c.Subject, c.Issuer, sans, c.NotAfter.Format(time.RFC3339),
+	)
+}
+
+// Output the TLS details of a connection to the specified address.
+func outputTLS(output func(string, ...interface{}), prefix string, addr net.Addr, details *TLSDetails) {
+	if details == nil {
+		output("%s %s: not using tls", prefix, addr)
+		return
+	}
+
+	output("%s %s:", prefix, addr)
+	for _, line := range details.Lines() {
+		output("  %s", line)
+	}
+}
+
+// loggedCredentials wraps the server transport credentials to log the TLS
+// details of every new client connection after the handshake completes.
+type loggedCredentials struct {
+	credentials.TransportCredentials
+}
+
+// ServerHandshake implements credentials.TransportCredentials
+func (c *loggedCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	remote := conn.RemoteAddr()
+	conn, info, err := c.TransportCredentials.ServerHandshake(conn)
+	if err != nil {
+		Output("tls handshake with %s failed: %s", remote, err)
+		return conn, info, err
+	}
+
+	if tlsInfo, ok := info.(credentials.TLSInfo); ok {
+		outputTLS(Output, "new tls connection from", remote, NewTLSDetails(tlsInfo.State))
+	}
+
+	countHandshake(info)
+
+	return conn, info, err
+}
+
+// Clone implements credentials.TransportCredentials
+func (c *loggedCredentials) Clone() credentials.TransportCredentials {
+	return &loggedCredentials{c.TransportCredentials.Clone()}
+}
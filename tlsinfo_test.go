@@ -0,0 +1,130 @@
+package sping
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestCertDetails(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, leaf := testCertificate(t, "localhost", expires)
+	leaf.EmailAddresses = []string{"admin@example.com"}
+
+	cert := NewCertDetails(leaf)
+	if cert.Subject != "CN=localhost" || cert.Issuer != "CN=localhost" {
+		t.Errorf("unexpected subject %q and issuer %q", cert.Subject, cert.Issuer)
+	}
+
+	expected := `subject "CN=localhost" issuer "CN=localhost" sans [localhost, 127.0.0.1, admin@example.com] expires 2030-01-02T03:04:05Z`
+	if s := cert.String(); s != expected {
+		t.Errorf("unexpected certificate details\n got %s\nwant %s", s, expected)
+	}
+
+	if s := (CertDetails{NotAfter: expires}).String(); !strings.Contains(s, "sans [none]") {
+		t.Errorf("expected a certificate without sans got %q", s)
+	}
+}
+
+func TestPeerTLSDetails(t *testing.T) {
+	if PeerTLSDetails(nil) != nil || PeerTLSDetails(&peer.Peer{}) != nil {
+		t.Error("expected no details for peers without tls")
+	}
+
+	_, leaf := testCertificate(t, "localhost", time.Now().Add(time.Hour))
+	details := PeerTLSDetails(&peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+		Version:          tls.VersionTLS12,
+		CipherSuite:      tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		DidResume:        true,
+		PeerCertificates: []*x509.Certificate{leaf},
+	}}})
+
+	if details == nil {
+		t.Fatal("expected the details of a tls peer")
+	}
+
+	lines := details.Lines()
+	expected := []string{
+		"version: TLS 1.2",
+		"cipher suite: TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"alpn: none",
+		"resumed: true",
+	}
+
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("expected %d lines got %d", len(expected)+1, len(lines))
+	}
+
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("expected line %d to be %q got %q", i, line, lines[i])
+		}
+	}
+
+	if !strings.HasPrefix(lines[4], `certificate 0: subject "CN=localhost"`) {
+		t.Errorf("unexpected certificate line %q", lines[4])
+	}
+}
+
+func TestLoggedCredentials(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	logmsgs = true
+	defer func() {
+		log.SetOutput(os.Stderr)
+		logmsgs = false
+	}()
+
+	cert, leaf := testCertificate(t, "localhost", time.Now().Add(time.Hour))
+	creds := &loggedCredentials{credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	// The details of successful handshakes are logged
+	server, client := net.Pipe()
+	go func() {
+		// Read until the server closes the pipe to receive the session tickets
+		conn := tls.Client(client, &tls.Config{RootCAs: pool, ServerName: "localhost"})
+		if err := conn.Handshake(); err == nil {
+			ioutil.ReadAll(conn)
+		}
+	}()
+
+	conn, info, err := creds.ServerHandshake(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if info.AuthType() != "tls" {
+		t.Errorf("expected tls auth info got %s", info.AuthType())
+	}
+
+	if out := buf.String(); !strings.Contains(out, "new tls connection from") || !strings.Contains(out, "cipher suite: ") {
+		t.Errorf("expected the tls details to be logged got %q", out)
+	}
+
+	// Failed handshakes are logged with the error
+	buf.Reset()
+	server, client = net.Pipe()
+	client.Close()
+
+	if _, _, err = creds.ServerHandshake(server); err == nil {
+		t.Fatal("expected the handshake to fail")
+	}
+
+	if out := buf.String(); !strings.Contains(out, "tls handshake with") || !strings.Contains(out, "failed") {
+		t.Errorf("expected the failed handshake to be logged got %q", out)
+	}
+}
@@ -0,0 +1,463 @@
+package sping
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Tracing defaults
+const (
+	DefaultTraceEndpoint = "http://localhost:4318/v1/traces"
+	DefaultTraceService  = "sping"
+	TraceFlushInterval   = 5 * time.Second
+)
+
+// The gRPC metadata key of the W3C trace context propagated with each ping.
+const traceparentKey = "traceparent"
+
+// The name of the traced Echo spans, following the gRPC semantic conventions.
+const echoSpanName = "echo.SecurePing/Echo"
+
+// TraceID identifies all of the spans of a trace.
+type TraceID [16]byte
+
+// String returns the trace ID as lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span of a trace.
+type SpanID [8]byte
+
+// String returns the span ID as lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanKind is the role of a span in an RPC, the values are the OTLP kinds.
+type SpanKind int
+
+// Span kinds of the client and server sides of the Echo RPC.
+const (
+	SpanKindServer SpanKind = 2
+	SpanKindClient SpanKind = 3
+)
+
+// Span is a timed operation of a trace that is exported to an OpenTelemetry
+// collector. Methods of a nil span do nothing so that tracing is optional.
+type Span struct {
+	TraceID    TraceID                // the trace the span belongs to
+	SpanID     SpanID                 // the unique ID of the span
+	ParentID   SpanID                 // the span that caused this span, if any
+	Name       string                 // the name of the operation
+	Kind       SpanKind               // client or server
+	Start      time.Time              // when the operation started
+	End        time.Time              // when the operation finished
+	Attributes map[string]interface{} // strings, bools, integers or floats
+	Err        string                 // the error of a failed operation
+	tracer     *Tracer
+}
+
+// SetAttribute records a property of the operation on the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Attributes[key] = value
+}
+
+// Finish ends the span, marking it as failed if err is not nil, and queues it
+// to be exported by the tracer.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.tracer.queue(s)
+}
+
+// Returns the W3C traceparent header of the span, which is always sampled.
+func (s *Span) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// Parses a W3C traceparent header into the trace and parent span IDs.
+func parseTraceparent(header string) (trace TraceID, parent SpanID, err error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return trace, parent, fmt.Errorf("invalid traceparent %q", header)
+	}
+
+	if _, err = hex.Decode(trace[:], []byte(parts[1])); err != nil {
+		return trace, parent, fmt.Errorf("invalid trace id in traceparent: %s", err)
+	}
+
+	if _, err = hex.Decode(parent[:], []byte(parts[2])); err != nil {
+		return trace, parent, fmt.Errorf("invalid parent id in traceparent: %s", err)
+	}
+	return trace, parent, nil
+}
+
+// SpanExporter sends batches of spans, encoded as an OTLP JSON export request,
+// to wherever they are collected.
+type SpanExporter interface {
+	Export(request []byte) error
+}
+
+// StdoutExporter writes each batch of spans as a line of OTLP JSON.
+type StdoutExporter struct {
+	Writer io.Writer
+}
+
+// Export implements SpanExporter
+func (e *StdoutExporter) Export(request []byte) error {
+	_, err := e.Writer.Write(append(request, '\n'))
+	return err
+}
+
+// OTLPExporter posts batches of spans to the OTLP/HTTP endpoint of a
+// collector using the JSON encoding.
+type OTLPExporter struct {
+	Endpoint string       // the traces endpoint, e.g. DefaultTraceEndpoint
+	Client   *http.Client // the client used to post the spans
+}
+
+// NewOTLPExporter creates an exporter for the traces endpoint of a collector.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = DefaultTraceEndpoint
+	}
+	return &OTLPExporter{Endpoint: endpoint, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Export implements SpanExporter
+func (e *OTLPExporter) Export(request []byte) error {
+	rep, err := e.Client.Post(e.Endpoint, "application/json", bytes.NewReader(request))
+	if err != nil {
+		return fmt.Errorf("could not export spans: %s", err)
+	}
+	defer rep.Body.Close()
+
+	if rep.StatusCode < 200 || rep.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(rep.Body, 512))
+		return fmt.Errorf("could not export spans: %s %s", rep.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Tracer creates the spans of the Echo RPCs and exports them in batches in
+// the background. Methods of a nil tracer do nothing so that it is optional.
+type Tracer struct {
+	sync.Mutex
+	Service  string        // the service.name of the exported spans
+	Interval time.Duration // how often the finished spans are exported
+	exporter SpanExporter  // where the finished spans are exported to
+	pending  []*Span       // the finished spans that have not been exported
+	stop     chan struct{} // closed to stop exporting spans
+	done     chan struct{} // closed when the export routine has returned
+	once     sync.Once     // ensures the tracer is only stopped once
+}
+
+// NewTracer creates a tracer of the service that exports spans every flush
+// interval to the exporter.
+func NewTracer(service string, exporter SpanExporter) *Tracer {
+	if service == "" {
+		service = DefaultTraceService
+	}
+
+	return &Tracer{
+		Service:  service,
+		Interval: TraceFlushInterval,
+		exporter: exporter,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start exporting the finished spans every interval until the tracer is stopped.
+func (t *Tracer) Start() {
+	if t == nil {
+		return
+	}
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Flush(); err != nil {
+					Output("%s", err)
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop exporting spans periodically and export the remaining spans.
+func (t *Tracer) Stop() error {
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	t.once.Do(func() {
+		close(t.stop)
+		<-t.done
+		err = t.Flush()
+	})
+	return err
+}
+
+// Flush exports all of the finished spans.
+func (t *Tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	request, err := json.Marshal(newOTLPRequest(t.Service, spans))
+	if err != nil {
+		return fmt.Errorf("could not encode spans: %s", err)
+	}
+	return t.exporter.Export(request)
+}
+
+// StartSpan starts a span of the Echo RPC. Server spans continue the trace
+// propagated by the client in the incoming metadata, while client spans start
+// a new trace that is propagated in the outgoing metadata of the returned
+// context.
+func (t *Tracer) StartSpan(ctx context.Context, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		Name:       echoSpanName,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{"rpc.system": "grpc", "rpc.service": "echo.SecurePing", "rpc.method": "Echo"},
+		tracer:     t,
+	}
+	rand.Read(span.SpanID[:])
+
+	if kind == SpanKindServer {
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[traceparentKey]) > 0 {
+			if trace, parent, err := parseTraceparent(md[traceparentKey][0]); err == nil {
+				span.TraceID, span.ParentID = trace, parent
+			}
+		}
+	}
+
+	if span.TraceID == (TraceID{}) {
+		rand.Read(span.TraceID[:])
+	}
+
+	if kind == SpanKindClient {
+		ctx = metadata.AppendToOutgoingContext(ctx, traceparentKey, span.traceparent())
+	}
+	return ctx, span
+}
+
+// Queues a finished span to be exported.
+func (t *Tracer) queue(span *Span) {
+	t.Lock()
+	defer t.Unlock()
+	t.pending = append(t.pending, span)
+}
+
+// Traces the Echo RPCs received by the server with the sender and sequence
+// numbers of the ping, continuing the trace of the client if it has one.
+func (s *PingServer) traceEcho(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != echoMethod {
+		return handler(ctx, req)
+	}
+
+	ctx, span := s.Tracer.StartSpan(ctx, SpanKindServer)
+	if ping, ok := req.(*pb.Ping); ok {
+		span.SetAttribute("sping.sender", senderIdentity(ctx, ping.Sender))
+		span.SetAttribute("sping.sseq", ping.Sseq)
+	}
+
+	rep, err := handler(ctx, req)
+	if pong, ok := rep.(*pb.Pong); ok && pong != nil {
+		span.SetAttribute("sping.rseq", pong.Rseq)
+		span.SetAttribute("sping.success", pong.Success)
+	}
+
+	span.Finish(err)
+	return rep, err
+}
+
+// Sends the ping with the client in a span that propagates the trace context
+// to the server, recording the sequence numbers and success of the pong.
+func (c *PingClient) tracedEcho(ctx context.Context, client pb.SecurePingClient, ping *pb.Ping, opts ...grpc.CallOption) (*pb.Pong, error) {
+	ctx, span := c.Tracer.StartSpan(ctx, SpanKindClient)
+	span.SetAttribute("sping.sender", ping.Sender)
+	span.SetAttribute("sping.sseq", ping.Sseq)
+	if c.Target != "" {
+		span.SetAttribute("net.peer.name", c.Target)
+	}
+
+	pong, err := client.Echo(ctx, ping, opts...)
+	if err == nil {
+		span.SetAttribute("sping.rseq", pong.Rseq)
+		span.SetAttribute("sping.success", pong.Success)
+	}
+
+	span.Finish(err)
+	return pong, err
+}
+
+// Chains the unary interceptors of the server, the first is the outermost,
+// since only one interceptor can be registered with the server.
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// The OTLP/JSON encoding of an ExportTraceServiceRequest, where IDs are hex
+// and 64 bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1 is ok and 2 is error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// Creates the export request of the spans of the service.
+func newOTLPRequest(service string, spans []*Span) *otlpRequest {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/bbengfort/sping"}}
+	for _, span := range spans {
+		encoded := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: 1},
+		}
+
+		if span.ParentID != (SpanID{}) {
+			encoded.ParentSpanID = span.ParentID.String()
+		}
+
+		if span.Err != "" {
+			encoded.Status = otlpStatus{Code: 2, Message: span.Err}
+		}
+		scope.Spans = append(scope.Spans, encoded)
+	}
+
+	resource := otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": service})}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{Resource: resource, ScopeSpans: []otlpScopeSpans{scope}}}}
+}
+
+// Encodes the attributes as OTLP key values sorted by key.
+func otlpAttributes(attrs map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attrs[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+	return encoded
+}
@@ -0,0 +1,140 @@
+package sping
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/bbengfort/sping/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceparent(t *testing.T) {
+	trace, parent, err := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trace.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected trace %s and parent %s", trace, parent)
+	}
+
+	for _, header := range []string{"", "00-4bf92f35-00f067aa0ba902b7-01", "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, _, err := parseTraceparent(header); err == nil {
+			t.Errorf("expected %q to be an invalid traceparent", header)
+		}
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	var requests []*otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request := new(otlpRequest)
+		if err := json.Unmarshal(body, request); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, request)
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer("tester", NewOTLPExporter(collector.URL))
+	server := &PingServer{Tracer: tracer, senders: make(map[string]*SenderState)}
+	info := &grpc.UnaryServerInfo{FullMethod: echoMethod}
+	logmsgs = false
+
+	// The client span propagates the trace in the outgoing metadata, which
+	// the server receives as incoming metadata
+	ctx, client := tracer.StartSpan(context.Background(), SpanKindClient)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ctx = metadata.NewIncomingContext(context.Background(), md)
+
+	ping := &pb.Ping{Sender: "tester", Sseq: 1}
+	if _, err := server.traceEcho(ctx, ping, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return server.Echo(ctx, req.(*pb.Ping))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client.Finish(nil)
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("expected one export request, got %d", len(requests))
+	}
+
+	spans := requests[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected a server and a client span, got %d", len(spans))
+	}
+
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.Kind != SpanKindServer || clientSpan.Kind != SpanKindClient {
+		t.Errorf("unexpected span kinds %d and %d", serverSpan.Kind, clientSpan.Kind)
+	}
+
+	if serverSpan.TraceID != clientSpan.TraceID || serverSpan.ParentSpanID != clientSpan.SpanID {
+		t.Errorf("server span %s/%s is not a child of client span %s/%s", serverSpan.TraceID, serverSpan.ParentSpanID, clientSpan.TraceID, clientSpan.SpanID)
+	}
+
+	attrs := make(map[string]interface{})
+	for _, attr := range serverSpan.Attributes {
+		for _, value := range attr.Value {
+			attrs[attr.Key] = value
+		}
+	}
+
+	expected := map[string]interface{}{"sping.sender": "tester", "sping.sseq": "1", "sping.rseq": "1", "sping.success": true}
+	for key, value := range expected {
+		if attrs[key] != value {
+			t.Errorf("expected server span attribute %s=%v, got %v", key, value, attrs[key])
+		}
+	}
+
+	// Nothing is exported without finished spans
+	if err := tracer.Flush(); err != nil || len(requests) != 1 {
+		t.Errorf("expected no export without spans, got %d requests (%v)", len(requests), err)
+	}
+}
+
+func TestChainUnary(t *testing.T) {
+	var calls []string
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+
+	chain := chainUnary(interceptor("outer"), interceptor("inner"))
+	chain(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	})
+
+	if len(calls) != 3 || calls[0] != "outer" || calls[1] != "inner" || calls[2] != "handler" {
+		t.Errorf("unexpected interceptor order %v", calls)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.StartSpan(context.Background(), SpanKindClient)
+	span.SetAttribute("sping.sseq", 1)
+	span.Finish(nil)
+
+	if _, ok := metadata.FromOutgoingContext(ctx); ok {
+		t.Error("expected a nil tracer not to propagate a trace")
+	}
+
+	if err := tracer.Stop(); err != nil {
+		t.Error(err)
+	}
+}
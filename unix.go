@@ -0,0 +1,144 @@
+package sping
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// The prefix of unix domain socket addresses, e.g. unix:///run/sping.sock
+const unixPrefix = "unix://"
+
+// PeerCredInfo is the AuthInfo of connections over a unix domain socket,
+// identifying the local process that connected with SO_PEERCRED.
+type PeerCredInfo struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// AuthType implements credentials.AuthInfo
+func (p *PeerCredInfo) AuthType() string {
+	return "peercred"
+}
+
+// String returns the identity of the peer used to track its sequence.
+func (p *PeerCredInfo) String() string {
+	return fmt.Sprintf("uid:%d", p.UID)
+}
+
+// unixCredentials are insecure transport credentials that perform no
+// handshake, but identify clients that connect over a unix domain socket by
+// their peer credentials, optionally only allowing the specified users.
+type unixCredentials struct {
+	uids []uint32
+}
+
+// ClientHandshake implements credentials.TransportCredentials
+func (c *unixCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, nil, nil
+}
+
+// ServerHandshake implements credentials.TransportCredentials
+func (c *unixCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return conn, nil, nil
+	}
+
+	cred, err := peerCred(uconn)
+	if err != nil {
+		if len(c.uids) > 0 {
+			return nil, nil, err
+		}
+		return conn, nil, nil
+	}
+
+	if len(c.uids) > 0 && !containsUID(c.uids, cred.UID) {
+		Output("refused unix connection from uid %d (pid %d)", cred.UID, cred.PID)
+		return nil, nil, fmt.Errorf("uid %d is not allowed to connect", cred.UID)
+	}
+
+	return conn, cred, nil
+}
+
+// Info implements credentials.TransportCredentials
+func (c *unixCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "insecure"}
+}
+
+// Clone implements credentials.TransportCredentials
+func (c *unixCredentials) Clone() credentials.TransportCredentials {
+	return &unixCredentials{uids: c.uids}
+}
+
+// OverrideServerName implements credentials.TransportCredentials
+func (c *unixCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// Returns true if the uid is in the list of uids.
+func containsUID(uids []uint32, uid uint32) bool {
+	for _, allowed := range uids {
+		if allowed == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sender identity used to track the sequence of a ping: the name
+// of the sender, qualified by its uid if it connected over a unix socket.
+func senderIdentity(ctx context.Context, sender string) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		if cred, ok := p.AuthInfo.(*PeerCredInfo); ok {
+			return fmt.Sprintf("%s@%s", sender, cred)
+		}
+	}
+	return sender
+}
+
+// Removes a stale unix socket left behind by a server that did not shutdown
+// cleanly, refusing to remove files that are not sockets.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	// Do not remove the socket of a running server
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
+
+// Returns the dial target and options to connect to the address, dialing a
+// unix domain socket if the address has the unix:// prefix.
+func dialTarget(addr string) (string, []grpc.DialOption) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return addr, nil
+	}
+
+	path := strings.TrimPrefix(addr, unixPrefix)
+	dialer := func(_ string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", path, timeout)
+	}
+	return path, []grpc.DialOption{grpc.WithDialer(dialer)}
+}
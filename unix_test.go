@@ -0,0 +1,60 @@
+package sping
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Refuse to remove files that are not sockets
+	path := filepath.Join(dir, "sping.sock")
+	if err = ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = removeStaleSocket(path); err == nil {
+		t.Error("expected a regular file not to be removed")
+	}
+	os.Remove(path)
+
+	logmsgs = false
+	server := NewServer()
+	server.UnixUIDs = []uint32{uint32(os.Getuid())}
+	go server.ServeListeners(Listener{"unix", path, SecurityInsecure})
+	defer server.Shutdown()
+
+	client := NewClient(Insecure, unixPrefix+path, "tester", 100, 1)
+	defer client.Connection.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pong, err := client.Echo(ctx, client.Next(), grpc.FailFast(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pong.Success || pong.Rseq != 1 {
+		t.Errorf("unexpected pong %v", pong)
+	}
+
+	// The sender is tracked by its name and uid
+	server.Lock()
+	defer server.Unlock()
+	if _, ok := server.senders[fmt.Sprintf("tester@uid:%d", os.Getuid())]; !ok {
+		t.Errorf("sender was not identified by its uid: %v", server.senders)
+	}
+}